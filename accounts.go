@@ -0,0 +1,182 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	maxLinkedAccounts = 10
+	maxAccountLabel   = 32
+)
+
+var ErrTooManyAccounts = errors.New("слишком много привязанных аккаунтов")
+
+// Steam аккаунт, привязанный пользователем Telegram
+type LinkedAccount struct {
+	SteamID string    `json:"steam_id"`
+	Label   string    `json:"label"`
+	AddedAt time.Time `json:"added_at"`
+}
+
+// Запись хранилища: все аккаунты одного пользователя
+type userAccounts struct {
+	UserID   int64           `json:"user_id"`
+	Accounts []LinkedAccount `json:"accounts"`
+}
+
+// Хранилище привязанных аккаунтов (пространство имен accounts)
+type AccountStore struct {
+	users *Store[userAccounts]
+}
+
+func NewAccountStore(backend CacheBackend) *AccountStore {
+	return &AccountStore{users: NewStore[userAccounts]("аккаунтов", backend)}
+}
+
+func accountsKey(userID int64) string {
+	return strconv.FormatInt(userID, 10)
+}
+
+// Привязываем аккаунт. Повторная привязка того же Steam ID меняет подпись
+func (s *AccountStore) Link(userID int64, account LinkedAccount) error {
+	var err error
+	s.users.Update(accountsKey(userID), func(user *userAccounts, exists bool) storeAction {
+		accounts := append([]LinkedAccount(nil), user.Accounts...)
+		*user = userAccounts{UserID: userID, Accounts: accounts}
+
+		for i, existing := range accounts {
+			if existing.SteamID == account.SteamID {
+				accounts[i].Label = account.Label
+				return storeSave
+			}
+		}
+
+		if len(accounts) >= maxLinkedAccounts {
+			err = ErrTooManyAccounts
+			return storeKeep
+		}
+
+		user.Accounts = append(accounts, account)
+		return storeSave
+	})
+	return err
+}
+
+// Отвязываем аккаунт по Steam ID или подписи
+func (s *AccountStore) Unlink(userID int64, query string) (LinkedAccount, bool) {
+	var removed LinkedAccount
+	found := false
+	s.users.Update(accountsKey(userID), func(user *userAccounts, exists bool) storeAction {
+		for i, account := range user.Accounts {
+			if account.SteamID == query || strings.EqualFold(account.Label, query) {
+				removed, found = account, true
+				user.Accounts = append(append([]LinkedAccount(nil), user.Accounts[:i]...), user.Accounts[i+1:]...)
+				if len(user.Accounts) == 0 {
+					return storeDelete
+				}
+				return storeSave
+			}
+		}
+		return storeKeep
+	})
+	return removed, found
+}
+
+// Аккаунты пользователя в порядке привязки
+func (s *AccountStore) List(userID int64) []LinkedAccount {
+	user, _ := s.users.Get(accountsKey(userID))
+	return append([]LinkedAccount(nil), user.Accounts...)
+}
+
+func (s *AccountStore) Close() {
+	s.users.Close()
+}
+
+func (tb *TelegramBot) handleLinkCommand(chatID, userID int64, text string) {
+	parts := strings.Fields(text)
+	if len(parts) < 2 {
+		tb.sendMessage(chatID, "Использование: /link <steam\\_id> [подпись]\nПример: /link 76561198111717059 Склад")
+		return
+	}
+
+	steamID, ok := tb.resolveInput(chatID, parts[1])
+	if !ok {
+		return
+	}
+
+	label := strings.Join(parts[2:], " ")
+	if label == "" {
+		label = fmt.Sprintf("Аккаунт %d", len(tb.accounts.List(userID))+1)
+	}
+	if runes := []rune(label); len(runes) > maxAccountLabel {
+		label = string(runes[:maxAccountLabel])
+	}
+
+	err := tb.accounts.Link(userID, LinkedAccount{SteamID: steamID, Label: label, AddedAt: time.Now()})
+	if errors.Is(err, ErrTooManyAccounts) {
+		tb.sendMessage(chatID, fmt.Sprintf("🚦 Можно привязать не больше %d аккаунтов. Отвяжите лишние: /accounts", maxLinkedAccounts))
+		return
+	}
+
+	tb.sendMessage(chatID, fmt.Sprintf("🔗 Аккаунт *%s* (%s) привязан.\nВсе аккаунты: /accounts, общая стоимость: /portfolio", escapeMarkdown(label), steamID))
+}
+
+func (tb *TelegramBot) handleUnlinkCommand(chatID, userID int64, text string) {
+	query := strings.TrimSpace(strings.TrimPrefix(text, "/unlink"))
+	if query == "" {
+		tb.sendMessage(chatID, "Использование: /unlink <steam\\_id или подпись>")
+		return
+	}
+
+	account, ok := tb.accounts.Unlink(userID, query)
+	if !ok {
+		tb.sendMessage(chatID, "Такого аккаунта нет. Список: /accounts")
+		return
+	}
+
+	tb.sendMessage(chatID, fmt.Sprintf("✅ Аккаунт *%s* отвязан", escapeMarkdown(account.Label)))
+}
+
+// Список аккаунтов пользователя с кнопками отвязки
+func (tb *TelegramBot) renderAccounts(userID int64) (string, *tgbotapi.InlineKeyboardMarkup) {
+	accounts := tb.accounts.List(userID)
+	if len(accounts) == 0 {
+		return "🔗 У вас нет привязанных аккаунтов.\nПривязать: /link <steam\\_id> [подпись]", nil
+	}
+
+	var b strings.Builder
+	b.WriteString("🔗 *Ваши аккаунты*\n\n")
+
+	callbacks := make([]string, 0, len(accounts))
+	for i, account := range accounts {
+		fmt.Fprintf(&b, "%d. *%s* - %s\n", i+1, escapeMarkdown(account.Label), account.SteamID)
+		callbacks = append(callbacks, fmt.Sprintf("unlink_%d_%s", userID, account.SteamID))
+	}
+	b.WriteString("\nОбщая стоимость: /portfolio\nНажмите номер, чтобы отвязать аккаунт.")
+
+	return b.String(), deleteKeyboard(callbacks)
+}
+
+func (tb *TelegramBot) sendAccounts(chatID, userID int64) {
+	text, keyboard := tb.renderAccounts(userID)
+	tb.sendList(chatID, text, keyboard)
+}
+
+// Отвязываем аккаунт по кнопке и обновляем список. В группе кнопки чужого
+// списка не действуют
+func (tb *TelegramBot) unlinkAccount(chatID, userID, ownerID int64, messageID int, steamID string) {
+	if userID != ownerID {
+		return
+	}
+
+	tb.accounts.Unlink(userID, steamID)
+
+	text, keyboard := tb.renderAccounts(userID)
+	tb.editList(chatID, messageID, text, keyboard)
+}
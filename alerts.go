@@ -0,0 +1,383 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Вид задач планировщика для оповещений о ценах
+const alertKind = "alert"
+
+const (
+	// Как часто проверяем цену: совпадает со сроком общего кэша цен,
+	// поэтому оповещения на один предмет делят один запрос к Steam
+	alertPollInterval = 15 * time.Minute
+	// Через сколько повторить проверку, если торговая площадка недоступна или занята
+	alertRetryDelay  = 5 * time.Minute
+	maxAlertsPerChat = 20
+)
+
+// Условия оповещения
+const (
+	alertAbove  = "above"  // цена выше порога
+	alertBelow  = "below"  // цена ниже порога
+	alertChange = "change" // изменение в любую сторону на Threshold процентов
+	alertRise   = "rise"   // рост на Threshold процентов
+	alertFall   = "fall"   // падение на Threshold процентов
+)
+
+// Какая цена сравнивается с порогом
+const (
+	priceLowest = "lowest"
+	priceMedian = "median"
+)
+
+// Данные оповещения о цене
+type AlertPayload struct {
+	AppID          string  `json:"app_id"`
+	MarketHashName string  `json:"market_hash_name"`
+	Condition      string  `json:"condition"`
+	Threshold      float64 `json:"threshold"` // цена в рублях или процент
+	Source         string  `json:"source"`
+
+	// Для процентных условий - цена, от которой считается изменение
+	// (при срабатывании она сдвигается на текущую цену)
+	Reference float64 `json:"reference"`
+	// Для порогов: оповещение сработает, когда цена пересечет порог.
+	// После срабатывания ждем, пока цена вернется на другую сторону
+	Armed bool `json:"armed"`
+
+	LastPrice      float64   `json:"last_price"`
+	CheckedAt      time.Time `json:"checked_at"`
+	TriggeredAt    time.Time `json:"triggered_at"`
+	TriggeredPrice float64   `json:"triggered_price"`
+	Triggers       []Trigger `json:"triggers,omitempty"` // история срабатываний для сводки
+}
+
+// Разбираем "<market_hash_name> above|below <цена> [median|lowest] [app_id]"
+// или "<market_hash_name> change [+|-]<процент> [median|lowest] [app_id]"
+func ParseAlert(args []string) (AlertPayload, error) {
+	alert := AlertPayload{AppID: "730", Source: priceLowest} // CS:GO по умолчанию
+
+	keyword := -1
+	for i, arg := range args {
+		switch strings.ToLower(arg) {
+		case alertAbove, alertBelow, alertChange:
+			keyword = i
+		}
+	}
+	if keyword < 1 || keyword+1 >= len(args) {
+		return alert, errors.New("укажите предмет, условие и значение")
+	}
+
+	alert.MarketHashName = strings.Trim(strings.Join(args[:keyword], " "), "\"«»")
+	if alert.MarketHashName == "" {
+		return alert, errors.New("укажите предмет")
+	}
+
+	value := args[keyword+1]
+	threshold, err := strconv.ParseFloat(strings.TrimSuffix(strings.Replace(value, ",", ".", 1), "%"), 64)
+	if err != nil {
+		return alert, fmt.Errorf("не число: %s", value)
+	}
+
+	alert.Condition = strings.ToLower(args[keyword])
+	if alert.Condition == alertChange {
+		switch {
+		case strings.HasPrefix(value, "+"):
+			alert.Condition = alertRise
+		case strings.HasPrefix(value, "-"):
+			alert.Condition = alertFall
+		}
+		threshold = math.Abs(threshold)
+	}
+	if threshold <= 0 {
+		return alert, errors.New("значение должно быть больше нуля")
+	}
+	alert.Threshold = threshold
+
+	for _, option := range args[keyword+2:] {
+		switch lower := strings.ToLower(option); {
+		case lower == priceLowest || lower == priceMedian:
+			alert.Source = lower
+		case isAppID(option):
+			alert.AppID = option
+		default:
+			return alert, fmt.Errorf("неизвестный параметр: %s (median, lowest или номер игры, например 570)", option)
+		}
+	}
+
+	return alert, nil
+}
+
+// Цена нужного вида из обзора рынка (0, если ее нет)
+func (a AlertPayload) price(overview MarketPrice) float64 {
+	if a.Source == priceMedian {
+		return parsePrice(overview.Median)
+	}
+	return parsePrice(overview.Lowest)
+}
+
+// Условие - порог цены, а не процент изменения
+func (a AlertPayload) isThreshold() bool {
+	return a.Condition == alertAbove || a.Condition == alertBelow
+}
+
+// Выполняется ли пороговое условие при цене price
+func (a AlertPayload) crossed(price float64) bool {
+	switch a.Condition {
+	case alertAbove:
+		return price > a.Threshold
+	case alertBelow:
+		return price < a.Threshold
+	}
+	return false
+}
+
+// Проверяем оповещение при новой цене. Возвращаем true, если оно сработало;
+// состояние (Armed, Reference) обновляется в любом случае
+func (a *AlertPayload) Check(price float64) bool {
+	if a.isThreshold() {
+		if !a.crossed(price) {
+			a.Armed = true
+			return false
+		}
+		if !a.Armed {
+			return false
+		}
+		a.Armed = false
+		return true
+	}
+
+	if a.Reference <= 0 {
+		a.Reference = price
+		return false
+	}
+
+	change := (price - a.Reference) / a.Reference * 100
+	triggered := false
+	switch a.Condition {
+	case alertChange:
+		triggered = math.Abs(change) >= a.Threshold
+	case alertRise:
+		triggered = change >= a.Threshold
+	case alertFall:
+		triggered = -change >= a.Threshold
+	}
+	if triggered {
+		a.Reference = price
+	}
+	return triggered
+}
+
+// Условие словами: "минимальная цена выше 1200.00 ₽"
+func (a AlertPayload) Describe() string {
+	source := "минимальная цена"
+	if a.Source == priceMedian {
+		source = "медианная цена"
+	}
+
+	switch a.Condition {
+	case alertAbove:
+		return fmt.Sprintf("%s выше %.2f ₽", source, a.Threshold)
+	case alertBelow:
+		return fmt.Sprintf("%s ниже %.2f ₽", source, a.Threshold)
+	case alertRise:
+		return fmt.Sprintf("%s выросла на %g%%", source, a.Threshold)
+	case alertFall:
+		return fmt.Sprintf("%s упала на %g%%", source, a.Threshold)
+	default:
+		return fmt.Sprintf("%s изменилась на %g%%", source, a.Threshold)
+	}
+}
+
+// Идентификатор игры Steam: 730, 570, 440, 252490
+func isAppID(text string) bool {
+	appID, err := strconv.Atoi(text)
+	return err == nil && appID > 0
+}
+
+func alertID() string {
+	return "alert_" + strconv.FormatInt(time.Now().UnixNano(), 36)
+}
+
+// Оповещения чата в порядке создания
+func (tb *TelegramBot) chatAlerts(chatID int64) []ScheduledTask {
+	return tb.scheduler.List(alertKind, func(task ScheduledTask) bool {
+		return task.ChatID == chatID
+	})
+}
+
+func (tb *TelegramBot) handleAlertCommand(chatID, userID int64, text string) {
+	alert, err := ParseAlert(strings.Fields(text)[1:])
+	if err != nil {
+		tb.sendMessage(chatID, "❌ "+escapeMarkdown(err.Error())+"\n\nИспользование:\n/alert <market\\_hash\\_name> above|below <цена> [median|lowest] [app\\_id]\n/alert <market\\_hash\\_name> change [+|-]<процент> [median|lowest] [app\\_id]\nИгра по умолчанию - CS:GO (730)")
+		return
+	}
+
+	if len(tb.chatAlerts(chatID)) >= maxAlertsPerChat {
+		tb.sendMessage(chatID, fmt.Sprintf("🚦 В чате уже %d оповещений. Удалите лишние: /alerts", maxAlertsPerChat))
+		return
+	}
+
+	if ok, wait := tb.priceQuota.Allow(userID); !ok {
+		tb.sendMessage(chatID, "⏳ Слишком много запросов цен. Попробуйте снова через "+formatWait(wait)+".")
+		return
+	}
+
+	// Запрос цены может ждать лимита площадки: не задерживаем другие обновления
+	go tb.createAlert(chatID, userID, alert)
+}
+
+// Получаем текущую цену, проверяя, что предмет есть на площадке, и сохраняем оповещение
+// с этой ценой в качестве точки отсчета
+func (tb *TelegramBot) createAlert(chatID, userID int64, alert AlertPayload) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	overview, err := tb.steam.getMarketOverview(ctx, alert.AppID, alert.MarketHashName, false)
+	switch {
+	case errors.Is(err, ErrCircuitOpen):
+		tb.sendMessage(chatID, "🔌 Торговая площадка Steam сейчас недоступна. Попробуйте позже.")
+		return
+	case err != nil:
+		// Таймаут или ограничение частоты: название тут ни при чем
+		log.Printf("Ошибка получения цены для оповещения: %v", err)
+		tb.sendMessage(chatID, "⏳ Торговая площадка Steam не ответила. Оповещение не создано, попробуйте позже.")
+		return
+	}
+
+	price := alert.price(overview)
+	if price == 0 {
+		tb.sendMessage(chatID, fmt.Sprintf("❌ Нет цены для: %s (%s)\nПроверьте точное название (market\\_hash\\_name) и игру или выберите другую цену (median/lowest).",
+			escapeMarkdown(alert.MarketHashName), getGameName(alert.AppID)))
+		return
+	}
+
+	alert.LastPrice = price
+	alert.CheckedAt = time.Now()
+	alert.Reference = price
+	alert.Armed = !alert.crossed(price)
+
+	task := newTask(alertID(), alertKind, chatID, userID, alert)
+	task.Interval = alertPollInterval
+	task.NextRun = time.Now().Add(alertPollInterval)
+	tb.scheduler.Add(task)
+
+	text := fmt.Sprintf("🔔 Оповещение создано: *%s* (%s), %s\nСейчас: %.2f ₽", escapeMarkdown(alert.MarketHashName), getGameName(alert.AppID), alert.Describe(), price)
+	if !alert.Armed {
+		text += "\nУсловие уже выполняется - оповещу, когда цена пересечет порог снова."
+	}
+	tb.sendMessage(chatID, text)
+}
+
+// Список оповещений чата с кнопками удаления
+func (tb *TelegramBot) renderAlerts(chatID int64) (string, *tgbotapi.InlineKeyboardMarkup) {
+	alerts := tb.chatAlerts(chatID)
+	if len(alerts) == 0 {
+		return "🔕 В этом чате нет оповещений о ценах.\nСоздать: /alert <market\\_hash\\_name> above|below <цена>", nil
+	}
+
+	var b strings.Builder
+	b.WriteString("🔔 *Оповещения о ценах*\n\n")
+
+	callbacks := make([]string, 0, len(alerts))
+	for _, task := range alerts {
+		alert, ok := taskPayload[AlertPayload](task)
+		if !ok {
+			continue
+		}
+
+		fmt.Fprintf(&b, "%d. *%s*: %s\n", len(callbacks)+1, escapeMarkdown(alert.MarketHashName), alert.Describe())
+		if alert.LastPrice > 0 {
+			fmt.Fprintf(&b, "   сейчас %.2f ₽ (%s)", alert.LastPrice, alert.CheckedAt.Format("02.01 15:04"))
+		}
+		if !alert.TriggeredAt.IsZero() {
+			fmt.Fprintf(&b, ", срабатывало %s", alert.TriggeredAt.Format("02.01 15:04"))
+		}
+		b.WriteString("\n")
+		callbacks = append(callbacks, "alertdel_"+task.ID)
+	}
+	b.WriteString("\nНажмите номер, чтобы удалить оповещение.")
+
+	return b.String(), deleteKeyboard(callbacks)
+}
+
+func (tb *TelegramBot) sendAlerts(chatID int64) {
+	text, keyboard := tb.renderAlerts(chatID)
+	tb.sendList(chatID, text, keyboard)
+}
+
+// Удаляем оповещение по кнопке и обновляем список
+func (tb *TelegramBot) deleteAlert(chatID int64, messageID int, id string) {
+	// Кнопки из другого чата не должны удалять чужие оповещения
+	if task, exists := tb.scheduler.Get(id); exists && task.Kind == alertKind && task.ChatID == chatID {
+		tb.scheduler.Remove(id)
+	}
+
+	text, keyboard := tb.renderAlerts(chatID)
+	tb.editList(chatID, messageID, text, keyboard)
+}
+
+// Плановая проверка оповещения через общий кэш цен
+func (tb *TelegramBot) runAlert(task ScheduledTask) time.Time {
+	alert, ok := taskPayload[AlertPayload](task)
+	if !ok {
+		return time.Time{}
+	}
+
+	now := time.Now()
+
+	// Проверки оповещений уступают сканированиям бюджет запросов к площадке
+	if !tb.steam.Available(EndpointMarket) || tb.steam.Throttled() {
+		return now.Add(alertRetryDelay)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	overview, err := tb.steam.getMarketOverview(ctx, alert.AppID, alert.MarketHashName, false)
+	if err != nil {
+		return now.Add(alertRetryDelay)
+	}
+
+	price := alert.price(overview)
+	if price == 0 {
+		// Нет лотов - цену сравнить не с чем
+		return now.Add(task.Interval)
+	}
+
+	// Процентные условия считаются от точки отсчета, пороговые - от прошлой проверки
+	previous := alert.LastPrice
+	if !alert.isThreshold() {
+		previous = alert.Reference
+	}
+	triggered := alert.Check(price)
+	alert.LastPrice = price
+	alert.CheckedAt = now
+	if triggered {
+		alert.TriggeredAt = now
+		alert.TriggeredPrice = price
+		alert.Triggers = appendTrigger(alert.Triggers, Trigger{At: now, Value: price})
+	}
+
+	tb.scheduler.Update(task.ID, func(current *ScheduledTask) bool {
+		current.setPayload(alert)
+		return true
+	})
+
+	if triggered {
+		tb.sendMessage(task.ChatID, fmt.Sprintf("🔔 *Оповещение о цене*\n\n*%s*: %s\nБыло: %.2f ₽, сейчас: %.2f ₽ (%s)\n\nСписок оповещений: /alerts",
+			escapeMarkdown(alert.MarketHashName), alert.Describe(), previous, price, formatChange(previous, price)))
+	}
+
+	return now.Add(task.Interval)
+}
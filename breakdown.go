@@ -0,0 +1,192 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Сколько групп показываем в каждом разрезе, остальные объединяются в «Прочее»
+const breakdownGroupsLimit = 8
+
+// Сколько самых дорогих предметов учитываем в концентрации стоимости
+const concentrationTop = 10
+
+// Группа предметов в разрезе отчета
+type BreakdownGroup struct {
+	Name  string
+	Count int
+	Value float64
+}
+
+// Разбивка стоимости и количества предметов по категориям, редкости,
+// износу и коллекциям (по тегам Steam)
+type Breakdown struct {
+	Categories  []BreakdownGroup
+	Rarities    []BreakdownGroup
+	Exteriors   []BreakdownGroup
+	Collections []BreakdownGroup
+
+	TotalValue float64
+	TopShare   float64 // доля стоимости самых дорогих concentrationTop предметов
+}
+
+// Русские названия категорий по тегу Type
+var categoryNames = map[string]string{
+	"Pistol":          "Скины оружия",
+	"Rifle":           "Скины оружия",
+	"SMG":             "Скины оружия",
+	"Sniper Rifle":    "Скины оружия",
+	"Shotgun":         "Скины оружия",
+	"Machinegun":      "Скины оружия",
+	"Knife":           "Ножи",
+	"Gloves":          "Перчатки",
+	"Container":       "Кейсы",
+	"Sticker":         "Наклейки",
+	"Graffiti":        "Граффити",
+	"Agent":           "Агенты",
+	"Music Kit":       "Наборы музыки",
+	"Patch":           "Нашивки",
+	"Collectible":     "Коллекционные",
+	"Key":             "Ключи",
+	"Pass":            "Пропуски",
+	"Tool":            "Инструменты",
+	"Equipment":       "Снаряжение",
+	"Charm":           "Брелоки",
+	"Sealed Graffiti": "Граффити",
+}
+
+// Категория предмета: по тегу Type, а без тегов - по строке типа
+func itemCategory(item InventoryItem) string {
+	tag := item.Tag("Type")
+	if tag == "" {
+		tag = item.Type
+	}
+	if tag == "" {
+		return "Другое"
+	}
+	if name, exists := categoryNames[tag]; exists {
+		return name
+	}
+	return tag
+}
+
+func BuildBreakdown(result *ScanResult) Breakdown {
+	var breakdown Breakdown
+
+	categories := make(map[string]*BreakdownGroup)
+	rarities := make(map[string]*BreakdownGroup)
+	exteriors := make(map[string]*BreakdownGroup)
+	collections := make(map[string]*BreakdownGroup)
+
+	for _, item := range result.Items {
+		breakdown.TotalValue += item.Value()
+
+		addToGroup(categories, itemCategory(item), item)
+		addToGroup(rarities, item.Tag("Rarity"), item)
+		addToGroup(exteriors, item.Tag("Exterior"), item)
+		addToGroup(collections, item.Tag("ItemSet"), item)
+	}
+
+	breakdown.Categories = sortedGroups(categories)
+	breakdown.Rarities = sortedGroups(rarities)
+	breakdown.Exteriors = sortedGroups(exteriors)
+	breakdown.Collections = sortedGroups(collections)
+
+	// Концентрация: какую долю стоимости дают самые дорогие предметы
+	values := make([]float64, len(result.Items))
+	for i, item := range result.Items {
+		values[i] = item.Value()
+	}
+	sort.Sort(sort.Reverse(sort.Float64Slice(values)))
+
+	if breakdown.TotalValue > 0 {
+		top := 0.0
+		for i := 0; i < len(values) && i < concentrationTop; i++ {
+			top += values[i]
+		}
+		breakdown.TopShare = top / breakdown.TotalValue
+	}
+
+	return breakdown
+}
+
+// Добавляем предмет в группу. Предметы без тега в разрез не попадают
+func addToGroup(groups map[string]*BreakdownGroup, name string, item InventoryItem) {
+	if name == "" {
+		return
+	}
+
+	group, exists := groups[name]
+	if !exists {
+		group = &BreakdownGroup{Name: name}
+		groups[name] = group
+	}
+	group.Count += item.Quantity()
+	group.Value += item.Value()
+}
+
+// Группы по убыванию стоимости; лишние объединяются в «Прочее»
+func sortedGroups(groups map[string]*BreakdownGroup) []BreakdownGroup {
+	sorted := make([]BreakdownGroup, 0, len(groups))
+	for _, group := range groups {
+		sorted = append(sorted, *group)
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Value != sorted[j].Value {
+			return sorted[i].Value > sorted[j].Value
+		}
+		return sorted[i].Name < sorted[j].Name
+	})
+
+	if len(sorted) > breakdownGroupsLimit {
+		other := BreakdownGroup{Name: "Прочее"}
+		for _, group := range sorted[breakdownGroupsLimit-1:] {
+			other.Count += group.Count
+			other.Value += group.Value
+		}
+		sorted = append(sorted[:breakdownGroupsLimit-1], other)
+	}
+
+	return sorted
+}
+
+// Текст разбивки для отчета
+func (b Breakdown) Format() string {
+	var text strings.Builder
+	text.WriteString("🧩 *Состав инвентаря*\n")
+
+	sections := []struct {
+		title  string
+		groups []BreakdownGroup
+	}{
+		{"📂 По категориям", b.Categories},
+		{"💎 По редкости", b.Rarities},
+		{"🔧 По износу", b.Exteriors},
+		{"🗂 По коллекциям", b.Collections},
+	}
+
+	for _, section := range sections {
+		if len(section.groups) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(&text, "\n*%s:*\n", section.title)
+		for _, group := range section.groups {
+			fmt.Fprintf(&text, "• %s: %d шт., %.2f ₽ (%.1f%%)\n",
+				escapeMarkdown(group.Name), group.Count, group.Value, b.percent(group.Value))
+		}
+	}
+
+	fmt.Fprintf(&text, "\n🎯 Топ-%d предметов дают %.1f%% стоимости", concentrationTop, b.TopShare*100)
+
+	return text.String()
+}
+
+func (b Breakdown) percent(value float64) float64 {
+	if b.TotalValue == 0 {
+		return 0
+	}
+	return value / b.TotalValue * 100
+}
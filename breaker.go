@@ -0,0 +1,121 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Эндпоинт Steam признан недоступным, запрос отклонен без обращения к сети
+var ErrCircuitOpen = errors.New("эндпоинт Steam недоступен")
+
+type BreakerState int
+
+const (
+	BreakerClosed BreakerState = iota
+	BreakerOpen
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "работает"
+	case BreakerOpen:
+		return "недоступен"
+	case BreakerHalfOpen:
+		return "проверка"
+	default:
+		return "неизвестно"
+	}
+}
+
+// Circuit breaker для одного эндпоинта: после threshold ошибок подряд
+// отклоняет запросы на openTimeout, затем пропускает один пробный запрос
+type CircuitBreaker struct {
+	mutex       sync.Mutex
+	state       BreakerState
+	failures    int
+	threshold   int
+	openTimeout time.Duration
+	openedAt    time.Time
+	probing     bool
+}
+
+func NewCircuitBreaker(threshold int, openTimeout time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		threshold:   threshold,
+		openTimeout: openTimeout,
+	}
+}
+
+// Проверяем, можно ли выполнить запрос
+func (cb *CircuitBreaker) Allow() error {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+
+	switch cb.state {
+	case BreakerOpen:
+		if time.Since(cb.openedAt) < cb.openTimeout {
+			return ErrCircuitOpen
+		}
+		cb.state = BreakerHalfOpen
+		cb.probing = true
+		return nil
+	case BreakerHalfOpen:
+		if cb.probing {
+			return ErrCircuitOpen
+		}
+		cb.probing = true
+		return nil
+	}
+
+	return nil
+}
+
+// Запрос выполнен успешно
+func (cb *CircuitBreaker) OnSuccess() {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+
+	cb.state = BreakerClosed
+	cb.failures = 0
+	cb.probing = false
+}
+
+// Запрос завершился ошибкой Steam (сеть, 5xx)
+func (cb *CircuitBreaker) OnFailure() {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+
+	cb.failures++
+	cb.probing = false
+	if cb.state == BreakerHalfOpen || cb.failures >= cb.threshold {
+		cb.state = BreakerOpen
+		cb.openedAt = time.Now()
+	}
+}
+
+// Запрос отменен вызывающим кодом: результат ничего не говорит о Steam
+func (cb *CircuitBreaker) Release() {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+
+	cb.probing = false
+}
+
+func (cb *CircuitBreaker) State() BreakerState {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+
+	if cb.state == BreakerOpen && time.Since(cb.openedAt) >= cb.openTimeout {
+		return BreakerHalfOpen
+	}
+	return cb.state
+}
+
+// Ошибка открытого breaker с указанием эндпоинта
+func circuitOpenError(endpoint Endpoint) error {
+	return fmt.Errorf("%s: %w", endpoint, ErrCircuitOpen)
+}
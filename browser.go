@@ -0,0 +1,278 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Предметов на одной странице браузера инвентаря
+const browserPageSize = 10
+
+// Порядок сортировки и фильтр кодируются одной буквой, чтобы callback data
+// укладывалась в 64 байта Telegram: browse_<steam_id>_<app_id>_<page>_<sort><filter>
+const (
+	sortByPrice    = 'p'
+	sortByName     = 'n'
+	sortByQuantity = 'q'
+	sortByType     = 't'
+
+	filterAll        = 'a'
+	filterTradable   = 't'
+	filterUntradable = 'u'
+)
+
+var browserSorts = []byte{sortByPrice, sortByName, sortByQuantity, sortByType}
+var browserFilters = []byte{filterAll, filterTradable, filterUntradable}
+
+var sortNames = map[byte]string{
+	sortByPrice:    "по цене",
+	sortByName:     "по названию",
+	sortByQuantity: "по количеству",
+	sortByType:     "по типу",
+}
+
+var filterNames = map[byte]string{
+	filterAll:        "все",
+	filterTradable:   "обмениваемые",
+	filterUntradable: "без обмена",
+}
+
+// Состояние браузера, которое хранится в callback data кнопок
+type browserState struct {
+	SteamID string
+	AppID   string
+	Page    int
+	Sort    byte
+	Filter  byte
+}
+
+func (s browserState) callbackData(prefix string) string {
+	return fmt.Sprintf("%s_%s_%s_%d_%c%c", prefix, s.SteamID, s.AppID, s.Page, s.Sort, s.Filter)
+}
+
+func parseBrowserState(data string) (browserState, bool) {
+	parts := strings.Split(data, "_")
+	if len(parts) != 5 || len(parts[4]) != 2 {
+		return browserState{}, false
+	}
+
+	page, err := strconv.Atoi(parts[3])
+	if err != nil || page < 0 {
+		return browserState{}, false
+	}
+
+	state := browserState{
+		SteamID: parts[1],
+		AppID:   parts[2],
+		Page:    page,
+		Sort:    parts[4][0],
+		Filter:  parts[4][1],
+	}
+	if sortNames[state.Sort] == "" || filterNames[state.Filter] == "" {
+		return browserState{}, false
+	}
+
+	return state, true
+}
+
+// Следующее значение по кругу
+func nextOption(options []byte, current byte) byte {
+	for i, option := range options {
+		if option == current {
+			return options[(i+1)%len(options)]
+		}
+	}
+	return options[0]
+}
+
+// Строка браузера: одинаковые предметы объединяются
+type browserRow struct {
+	Item     InventoryItem
+	Quantity int
+	Total    float64
+}
+
+// Строим строки браузера из предметов результата, прошедших фильтр
+func browserRows(result *ScanResult, sortBy, filter byte) []browserRow {
+	var items []InventoryItem
+	for _, item := range result.Items {
+		if filter == filterTradable && !item.Tradable || filter == filterUntradable && item.Tradable {
+			continue
+		}
+		items = append(items, item)
+	}
+	return groupItems(items, sortBy)
+}
+
+// Объединяем одинаковые предметы в строки и сортируем их
+func groupItems(items []InventoryItem, sortBy byte) []browserRow {
+	index := make(map[string]int)
+	var rows []browserRow
+
+	for _, item := range items {
+		key := item.MarketHashName
+		if key == "" {
+			key = item.Name
+		}
+
+		if i, exists := index[key]; exists {
+			rows[i].Quantity += item.Quantity()
+			rows[i].Total += item.Value()
+			continue
+		}
+
+		index[key] = len(rows)
+		rows = append(rows, browserRow{Item: item, Quantity: item.Quantity(), Total: item.Value()})
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		a, b := rows[i], rows[j]
+		switch sortBy {
+		case sortByName:
+			return strings.ToLower(a.Item.Name) < strings.ToLower(b.Item.Name)
+		case sortByQuantity:
+			if a.Quantity != b.Quantity {
+				return a.Quantity > b.Quantity
+			}
+		case sortByType:
+			if a.Item.Type != b.Item.Type {
+				return a.Item.Type < b.Item.Type
+			}
+		}
+		return a.Item.PriceValue > b.Item.PriceValue
+	})
+
+	return rows
+}
+
+// Открываем браузер новым сообщением (кнопка под отчетом)
+func (tb *TelegramBot) openBrowser(chatID int64, state browserState) {
+	result, ok := tb.scanResult(chatID, state.SteamID, state.AppID)
+	if !ok {
+		return
+	}
+
+	text, keyboard := renderBrowser(result, &state)
+
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ParseMode = "Markdown"
+	msg.ReplyMarkup = keyboard
+
+	if _, err := tb.bot.Send(msg); err != nil {
+		log.Printf("Ошибка отправки сообщения: %v", err)
+	}
+}
+
+// Листаем браузер, редактируя сообщение на месте
+func (tb *TelegramBot) updateBrowser(chatID int64, messageID int, state browserState) {
+	result, ok := tb.scanResult(chatID, state.SteamID, state.AppID)
+	if !ok {
+		return
+	}
+
+	text, keyboard := renderBrowser(result, &state)
+
+	edit := tgbotapi.NewEditMessageTextAndMarkup(chatID, messageID, text, keyboard)
+	edit.ParseMode = "Markdown"
+
+	if _, err := tb.bot.Send(edit); err != nil {
+		log.Printf("Ошибка редактирования сообщения: %v", err)
+	}
+}
+
+// Результат сканирования для кнопок под отчетом; если он вытеснен из кэша, просим пересканировать
+func (tb *TelegramBot) scanResult(chatID int64, steamID, appID string) (*ScanResult, bool) {
+	entry, exists := tb.cache.GetEntry(scanKey(steamID, appID, defaultContextID, marketCurrency))
+	if !exists && isImportID(steamID) {
+		tb.sendMessage(chatID, "⌛ Результат оценки файла устарел. Пришлите файл заново.")
+		return nil, false
+	}
+	if !exists {
+		tb.sendMessage(chatID, "⌛ Результат сканирования устарел. Отсканируйте инвентарь заново: /scan "+steamID+" "+appID)
+		return nil, false
+	}
+	return entry.Value, true
+}
+
+// Текст страницы и кнопки навигации. Номер страницы приводится к допустимому
+func renderBrowser(result *ScanResult, state *browserState) (string, tgbotapi.InlineKeyboardMarkup) {
+	rows := browserRows(result, state.Sort, state.Filter)
+
+	pages := (len(rows) + browserPageSize - 1) / browserPageSize
+	if pages == 0 {
+		pages = 1
+	}
+	if state.Page >= pages {
+		state.Page = pages - 1
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "📋 *Инвентарь %s* (%s)\n", result.SteamID, getGameName(result.AppID))
+	fmt.Fprintf(&b, "Сортировка: %s • Фильтр: %s\n\n", sortNames[state.Sort], filterNames[state.Filter])
+
+	if len(rows) == 0 {
+		b.WriteString("Нет предметов под этот фильтр.")
+	}
+
+	start := state.Page * browserPageSize
+	for i := start; i < start+browserPageSize && i < len(rows); i++ {
+		row := rows[i]
+		fmt.Fprintf(&b, "%d. %s\n", i+1, escapeMarkdown(row.Item.Name))
+		if row.Quantity > 1 {
+			fmt.Fprintf(&b, "   💰 %.2f ₽ × %d = %.2f ₽", row.Item.PriceValue, row.Quantity, row.Total)
+		} else {
+			fmt.Fprintf(&b, "   💰 %.2f ₽", row.Item.PriceValue)
+		}
+		if !row.Item.Tradable {
+			b.WriteString(" 🔒")
+		}
+		b.WriteString("\n")
+	}
+
+	fmt.Fprintf(&b, "\nСтраница %d из %d", state.Page+1, pages)
+
+	prev, next := *state, *state
+	prev.Page = (state.Page - 1 + pages) % pages
+	next.Page = (state.Page + 1) % pages
+
+	sorted, filtered := *state, *state
+	sorted.Sort = nextOption(browserSorts, state.Sort)
+	sorted.Page = 0
+	filtered.Filter = nextOption(browserFilters, state.Filter)
+	filtered.Page = 0
+
+	// Кнопки карточек предметов текущей страницы
+	end := start + browserPageSize
+	if end > len(rows) {
+		end = len(rows)
+	}
+	pageItems := make([]InventoryItem, 0, end-start)
+	for _, row := range rows[start:end] {
+		pageItems = append(pageItems, row.Item)
+	}
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(append(itemButtons(result.SteamID, result.AppID, pageItems, start+1),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("◀️", prev.callbackData("browse")),
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("%d/%d", state.Page+1, pages), "noop"),
+			tgbotapi.NewInlineKeyboardButtonData("▶️", next.callbackData("browse")),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("↕️ "+sortNames[sorted.Sort], sorted.callbackData("browse")),
+			tgbotapi.NewInlineKeyboardButtonData("🔎 "+filterNames[filtered.Filter], filtered.callbackData("browse")),
+		),
+	)...)
+
+	return b.String(), keyboard
+}
+
+// Экранируем символы разметки Markdown в названиях предметов
+func escapeMarkdown(text string) string {
+	replacer := strings.NewReplacer("_", "\\_", "*", "\\*", "`", "\\`", "[", "\\[")
+	return replacer.Replace(text)
+}
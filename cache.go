@@ -1,28 +1,103 @@
 package main
 
 import (
+	"container/list"
+	"encoding/json"
+	"log"
 	"sync"
 	"time"
 )
 
-// Кэш для хранения результатов сканирования
-type Cache struct {
-	items map[string]CachedInventory
-	mutex sync.RWMutex
-	ttl   time.Duration
+// Настройки кэша
+type CacheOptions[V any] struct {
+	TTL        time.Duration // сколько данные считаются свежими
+	StaleTTL   time.Duration // сколько после TTL еще можно отдавать устаревшие данные
+	MaxEntries int           // 0 - без ограничения
+	MaxBytes   int64         // 0 - без ограничения
+	SizeOf     func(V) int64 // оценка размера значения для MaxBytes
+	Backend    CacheBackend  // постоянное хранилище, nil - только память
 }
 
-type CachedInventory struct {
-	Data      []InventoryItem
-	ExpiresAt time.Time
+// Кэш с вытеснением давно неиспользуемых записей (LRU) и отдачей
+// устаревших данных, пока они обновляются в фоне
+type Cache[V any] struct {
+	mutex   sync.Mutex
+	items   map[string]*list.Element
+	lru     *list.List // в начале - недавно использованные
+	options CacheOptions[V]
+	bytes   int64
+	stats   CacheStats
+
+	done      chan struct{}
+	closeOnce sync.Once
+	closed    bool
+
+	// Операции с хранилищем выполняются в отдельной горутине, чтобы не держать
+	// mutex во время записи на диск или в сеть. Для каждого ключа ждет только
+	// последняя операция: промежуточные сохранения устарели бы еще до записи
+	pending     map[string]backendOp
+	persistWake chan struct{} // в pending появились операции
+	persistDone chan struct{}
+}
+
+type backendOp struct {
+	key   string
+	entry *StoredEntry // nil - удалить запись
+}
+
+type CacheEntry[V any] struct {
+	Value     V
 	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+// Истек ли срок свежести записи
+func (e CacheEntry[V]) Stale() bool {
+	return time.Now().After(e.ExpiresAt)
+}
+
+type cacheItem[V any] struct {
+	key        string
+	entry      CacheEntry[V]
+	size       int64
+	refreshing bool
+}
+
+// Счетчики кэша
+type CacheStats struct {
+	Hits      uint64
+	StaleHits uint64
+	Misses    uint64
+	Evictions uint64
+	Entries   int
+	Bytes     int64
+}
+
+// Доля запросов, обслуженных из кэша
+func (s CacheStats) HitRatio() float64 {
+	total := s.Hits + s.StaleHits + s.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Hits+s.StaleHits) / float64(total)
 }
 
 // Создаем новый кэш
-func NewCache(ttl time.Duration) *Cache {
-	cache := &Cache{
-		items: make(map[string]CachedInventory),
-		ttl:   ttl,
+func NewCache[V any](options CacheOptions[V]) *Cache[V] {
+	cache := &Cache[V]{
+		items:   make(map[string]*list.Element),
+		lru:     list.New(),
+		options: options,
+		done:    make(chan struct{}),
+	}
+
+	if options.Backend != nil {
+		cache.restore()
+
+		cache.pending = make(map[string]backendOp)
+		cache.persistWake = make(chan struct{}, 1)
+		cache.persistDone = make(chan struct{})
+		go cache.writeBackend()
 	}
 
 	// Запускаем очистку устаревших данных
@@ -31,88 +106,329 @@ func NewCache(ttl time.Duration) *Cache {
 	return cache
 }
 
-// Получаем данные из кэша
-func (c *Cache) Get(key string) ([]InventoryItem, bool) {
-	c.mutex.RLock()
-	defer c.mutex.RUnlock()
+// Загружаем сохраненные записи из хранилища с исходными CreatedAt/ExpiresAt
+func (c *Cache[V]) restore() {
+	stored, err := c.options.Backend.Load()
+	if err != nil {
+		log.Printf("Ошибка загрузки кэша: %v", err)
+	}
+
+	now := time.Now()
+	restored := 0
+	for _, record := range stored {
+		var value V
+		if err := json.Unmarshal(record.Value, &value); err != nil {
+			continue
+		}
+
+		entry := CacheEntry[V]{Value: value, CreatedAt: record.CreatedAt, ExpiresAt: record.ExpiresAt}
+		if now.After(entry.ExpiresAt.Add(c.options.StaleTTL)) {
+			continue
+		}
+
+		c.insert(record.Key, entry)
+		restored++
+	}
+
+	if restored > 0 {
+		log.Printf("Восстановлено записей кэша: %d", restored)
+	}
+}
+
+// Применяем накопившиеся операции с хранилищем. После Close дописываем
+// оставшиеся и закрываем хранилище
+func (c *Cache[V]) writeBackend() {
+	defer close(c.persistDone)
+
+	for {
+		_, open := <-c.persistWake
+
+		c.mutex.Lock()
+		ops := c.pending
+		c.pending = make(map[string]backendOp)
+		c.mutex.Unlock()
+
+		for _, op := range ops {
+			var err error
+			if op.entry != nil {
+				err = c.options.Backend.Save(*op.entry)
+			} else {
+				err = c.options.Backend.Delete(op.key)
+			}
+			if err != nil {
+				log.Printf("Ошибка записи кэша в хранилище: %v", err)
+			}
+		}
+
+		if !open {
+			break
+		}
+	}
+
+	if err := c.options.Backend.Close(); err != nil {
+		log.Printf("Ошибка закрытия хранилища кэша: %v", err)
+	}
+}
+
+// Получаем свежие данные из кэша
+func (c *Cache[V]) Get(key string) (V, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
 
-	item, exists := c.items[key]
+	item, exists := c.lookup(key)
+	if !exists || item.entry.Stale() {
+		c.stats.Misses++
+		var zero V
+		return zero, false
+	}
+
+	c.stats.Hits++
+	return item.entry.Value, true
+}
+
+// Получаем запись вместе со временем создания. Устаревшая запись тоже
+// возвращается, пока не истек StaleTTL - вызывающий код решает, обновлять ли ее
+func (c *Cache[V]) GetEntry(key string) (CacheEntry[V], bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	item, exists := c.lookup(key)
+	if !exists {
+		c.stats.Misses++
+		return CacheEntry[V]{}, false
+	}
+
+	if item.entry.Stale() {
+		c.stats.StaleHits++
+	} else {
+		c.stats.Hits++
+	}
+	return item.entry, true
+}
+
+// Есть ли в кэше данные, которые можно отдать (не влияет на счетчики)
+func (c *Cache[V]) Has(key string) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	element, exists := c.items[key]
+	return exists && !c.expired(element.Value.(*cacheItem[V]), time.Now())
+}
+
+// Есть ли в кэше свежие данные (не влияет на счетчики)
+func (c *Cache[V]) Fresh(key string) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	element, exists := c.items[key]
+	return exists && !element.Value.(*cacheItem[V]).entry.Stale()
+}
+
+// Ищем запись и поднимаем ее в начало LRU (вызывать под mutex)
+func (c *Cache[V]) lookup(key string) (*cacheItem[V], bool) {
+	element, exists := c.items[key]
 	if !exists {
 		return nil, false
 	}
 
-	// Проверяем, не истек ли срок
-	if time.Now().After(item.ExpiresAt) {
+	item := element.Value.(*cacheItem[V])
+	if c.expired(item, time.Now()) {
+		c.remove(element)
 		return nil, false
 	}
 
-	return item.Data, true
+	c.lru.MoveToFront(element)
+	return item, true
 }
 
 // Сохраняем данные в кэш
-func (c *Cache) Set(key string, data []InventoryItem) {
+func (c *Cache[V]) Set(key string, value V) {
+	now := time.Now()
+	entry := CacheEntry[V]{
+		Value:     value,
+		CreatedAt: now,
+		ExpiresAt: now.Add(c.options.TTL),
+	}
+
+	// Кодируем до захвата mutex: большие инвентари сериализуются заметное время
+	var stored *StoredEntry
+	if c.options.Backend != nil {
+		data, err := json.Marshal(value)
+		if err != nil {
+			log.Printf("Ошибка сериализации записи кэша: %v", err)
+		} else {
+			stored = &StoredEntry{
+				Key:         key,
+				Value:       data,
+				CreatedAt:   entry.CreatedAt,
+				ExpiresAt:   entry.ExpiresAt,
+				DeleteAfter: entry.ExpiresAt.Add(c.options.StaleTTL),
+			}
+		}
+	}
+
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
-	c.items[key] = CachedInventory{
-		Data:      data,
-		ExpiresAt: time.Now().Add(c.ttl),
-		CreatedAt: time.Now(),
+	c.insert(key, entry)
+	if stored != nil {
+		c.enqueuePersist(backendOp{key: key, entry: stored})
 	}
 }
 
-// Очистка устаревших данных
-func (c *Cache) cleanup() {
-	ticker := time.NewTicker(5 * time.Minute)
-	defer ticker.Stop()
+// Добавляем запись и вытесняем лишние (вызывать под mutex)
+func (c *Cache[V]) insert(key string, entry CacheEntry[V]) {
+	var size int64
+	if c.options.SizeOf != nil {
+		size = c.options.SizeOf(entry.Value)
+	}
 
-	for range ticker.C {
-		c.mutex.Lock()
-		now := time.Now()
-		for key, item := range c.items {
-			if now.After(item.ExpiresAt) {
-				delete(c.items, key)
-			}
-		}
-		c.mutex.Unlock()
+	if element, exists := c.items[key]; exists {
+		c.detach(element)
 	}
+
+	item := &cacheItem[V]{key: key, entry: entry, size: size}
+	c.items[key] = c.lru.PushFront(item)
+	c.bytes += size
+
+	c.evict()
 }
 
-// Rate limiter для контроля запросов
-type RateLimiter struct {
-	requests chan struct{}
-	ticker   *time.Ticker
+// Передаем операцию горутине хранилища (вызывать под mutex). Не блокируется:
+// если хранилище не успевает, новая операция заменяет ждущую для того же ключа
+func (c *Cache[V]) enqueuePersist(op backendOp) {
+	if c.pending == nil || c.closed {
+		return
+	}
+
+	c.pending[op.key] = op
+	select {
+	case c.persistWake <- struct{}{}:
+	default:
+	}
 }
 
-// Создаем rate limiter
-func NewRateLimiter(interval time.Duration) *RateLimiter {
-	rl := &RateLimiter{
-		requests: make(chan struct{}, 1),
-		ticker:   time.NewTicker(interval),
+// Удаляем запись
+func (c *Cache[V]) Delete(key string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if element, exists := c.items[key]; exists {
+		c.remove(element)
 	}
+}
 
-	// Запускаем разрешение запросов
-	go rl.allowRequests()
+// Отмечаем, что устаревшая запись обновляется. Возвращает false, если
+// обновление уже идет или записи нет - тогда запускать его не нужно
+func (c *Cache[V]) BeginRefresh(key string) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
 
-	return rl
+	element, exists := c.items[key]
+	if !exists {
+		return false
+	}
+
+	item := element.Value.(*cacheItem[V])
+	if item.refreshing {
+		return false
+	}
+	item.refreshing = true
+	return true
 }
 
-// Разрешаем запросы с интервалом
-func (rl *RateLimiter) allowRequests() {
-	for range rl.ticker.C {
-		select {
-		case rl.requests <- struct{}{}:
-		default:
+// Обновление завершилось без новых данных: разрешаем следующую попытку
+func (c *Cache[V]) EndRefresh(key string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if element, exists := c.items[key]; exists {
+		element.Value.(*cacheItem[V]).refreshing = false
+	}
+}
+
+func (c *Cache[V]) Stats() CacheStats {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	stats := c.stats
+	stats.Entries = c.lru.Len()
+	stats.Bytes = c.bytes
+	return stats
+}
+
+// Останавливаем фоновую очистку и дожидаемся записи в хранилище
+func (c *Cache[V]) Close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+
+		c.mutex.Lock()
+		c.closed = true
+		if c.persistWake != nil {
+			close(c.persistWake)
+		}
+		c.mutex.Unlock()
+
+		if c.persistDone != nil {
+			<-c.persistDone
+		}
+	})
+}
+
+// Запись нельзя отдавать даже как устаревшую
+func (c *Cache[V]) expired(item *cacheItem[V], now time.Time) bool {
+	return now.After(item.entry.ExpiresAt.Add(c.options.StaleTTL))
+}
+
+// Вытесняем давно неиспользуемые записи сверх лимитов (вызывать под mutex)
+func (c *Cache[V]) evict() {
+	for c.lru.Len() > 1 {
+		overEntries := c.options.MaxEntries > 0 && c.lru.Len() > c.options.MaxEntries
+		overBytes := c.options.MaxBytes > 0 && c.bytes > c.options.MaxBytes
+		if !overEntries && !overBytes {
+			return
 		}
+
+		c.remove(c.lru.Back())
+		c.stats.Evictions++
 	}
 }
 
-// Ждем разрешения на запрос
-func (rl *RateLimiter) Wait() {
-	<-rl.requests
+// Удаляем запись из памяти и из хранилища (вызывать под mutex)
+func (c *Cache[V]) remove(element *list.Element) {
+	item := c.detach(element)
+	c.enqueuePersist(backendOp{key: item.key})
 }
 
-// Останавливаем rate limiter
-func (rl *RateLimiter) Stop() {
-	rl.ticker.Stop()
+// Удаляем запись только из памяти (вызывать под mutex)
+func (c *Cache[V]) detach(element *list.Element) *cacheItem[V] {
+	item := c.lru.Remove(element).(*cacheItem[V])
+	delete(c.items, item.key)
+	c.bytes -= item.size
+	return item
+}
+
+// Очистка устаревших данных
+func (c *Cache[V]) cleanup() {
+	ticker := time.NewTicker(5 * time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+		}
+
+		c.mutex.Lock()
+		now := time.Now()
+		for element := c.lru.Front(); element != nil; {
+			next := element.Next()
+			if c.expired(element.Value.(*cacheItem[V]), now) {
+				c.remove(element)
+			}
+			element = next
+		}
+		c.mutex.Unlock()
+	}
 }
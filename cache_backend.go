@@ -0,0 +1,422 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Запись кэша в постоянном хранилище
+type StoredEntry struct {
+	Key         string          `json:"key"`
+	Value       json.RawMessage `json:"value"`
+	CreatedAt   time.Time       `json:"created_at"`
+	ExpiresAt   time.Time       `json:"expires_at"`
+	DeleteAfter time.Time       `json:"delete_after"` // после этого запись не нужна даже как устаревшая
+}
+
+// Хранилище, в которое кэш дублирует записи, чтобы пережить перезапуск бота
+type CacheBackend interface {
+	Load() ([]StoredEntry, error)
+	Save(entry StoredEntry) error
+	Delete(key string) error
+	Close() error
+}
+
+// Создаем хранилище по переменным окружения:
+// CACHE_BACKEND=file (каталог CACHE_DIR) или redis (адрес REDIS_URL).
+// Без настройки кэш живет только в памяти
+func newCacheBackendFromEnv(namespace string) (CacheBackend, error) {
+	switch os.Getenv("CACHE_BACKEND") {
+	case "":
+		return nil, nil
+	case "file":
+		dir := os.Getenv("CACHE_DIR")
+		if dir == "" {
+			dir = "/data/cache"
+		}
+		return NewFileBackend(filepath.Join(dir, namespace))
+	case "redis":
+		return NewRedisBackend(os.Getenv("REDIS_URL"), namespace+":")
+	default:
+		return nil, fmt.Errorf("неизвестный CACHE_BACKEND: %s", os.Getenv("CACHE_BACKEND"))
+	}
+}
+
+// Хранилище в каталоге на диске (например, на подключенном volume): одна запись - один файл
+type FileBackend struct {
+	dir string
+}
+
+func NewFileBackend(dir string) (*FileBackend, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileBackend{dir: dir}, nil
+}
+
+// Имя файла из хэша ключа: ключи могут содержать любые символы
+func (fb *FileBackend) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(fb.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+func (fb *FileBackend) Load() ([]StoredEntry, error) {
+	paths, err := filepath.Glob(filepath.Join(fb.dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var entries []StoredEntry
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+
+		var entry StoredEntry
+		if err := json.Unmarshal(data, &entry); err != nil || now.After(entry.DeleteAfter) {
+			// Поврежденные и окончательно устаревшие записи удаляем
+			os.Remove(path)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+func (fb *FileBackend) Save(entry StoredEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	// Пишем во временный файл и переименовываем, чтобы не оставить половину записи
+	tmp, err := os.CreateTemp(fb.dir, "entry-*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), fb.path(entry.Key))
+}
+
+func (fb *FileBackend) Delete(key string) error {
+	err := os.Remove(fb.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (fb *FileBackend) Close() error {
+	return nil
+}
+
+// Хранилище в Redis (или любом сервере с протоколом RESP).
+// Записи хранятся как JSON под ключами prefix+key с TTL до DeleteAfter
+type RedisBackend struct {
+	mutex    sync.Mutex
+	prefix   string
+	password string
+	db       int
+	dial     func() (net.Conn, error)
+	conn     net.Conn
+	reader   *bufio.Reader
+}
+
+// Создаем хранилище по адресу вида redis://[:password@]host:port[/db]
+func NewRedisBackend(rawURL, prefix string) (*RedisBackend, error) {
+	if rawURL == "" {
+		return nil, errors.New("не задан REDIS_URL")
+	}
+
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	rb := &RedisBackend{prefix: prefix}
+	if parsed.User != nil {
+		rb.password, _ = parsed.User.Password()
+	}
+	if db := strings.TrimPrefix(parsed.Path, "/"); db != "" {
+		if rb.db, err = strconv.Atoi(db); err != nil {
+			return nil, fmt.Errorf("неверный номер базы Redis: %s", db)
+		}
+	}
+
+	address := parsed.Host
+	rb.dial = func() (net.Conn, error) {
+		return net.DialTimeout("tcp", address, 5*time.Second)
+	}
+
+	return rb, nil
+}
+
+// Создаем хранилище поверх произвольного соединения (например, встроенного RESP-сервера)
+func NewRedisBackendWithDialer(dial func() (net.Conn, error), prefix string) *RedisBackend {
+	return &RedisBackend{prefix: prefix, dial: dial}
+}
+
+func (rb *RedisBackend) Load() ([]StoredEntry, error) {
+	keys, err := rb.scanKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []StoredEntry
+	for _, key := range keys {
+		reply, err := rb.command("GET", key)
+		if err != nil {
+			return entries, err
+		}
+
+		data, ok := reply.(string)
+		if !ok {
+			continue // ключ успел истечь
+		}
+
+		var entry StoredEntry
+		if err := json.Unmarshal([]byte(data), &entry); err != nil {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// Собираем все ключи с нашим префиксом через SCAN
+func (rb *RedisBackend) scanKeys() ([]string, error) {
+	var keys []string
+	cursor := "0"
+
+	for {
+		reply, err := rb.command("SCAN", cursor, "MATCH", rb.prefix+"*", "COUNT", "100")
+		if err != nil {
+			return nil, err
+		}
+
+		parts, ok := reply.([]interface{})
+		if !ok || len(parts) != 2 {
+			return nil, errors.New("неожиданный ответ SCAN")
+		}
+
+		cursor, _ = parts[0].(string)
+		batch, _ := parts[1].([]interface{})
+		for _, key := range batch {
+			if s, ok := key.(string); ok {
+				keys = append(keys, s)
+			}
+		}
+
+		if cursor == "0" || cursor == "" {
+			return keys, nil
+		}
+	}
+}
+
+func (rb *RedisBackend) Save(entry StoredEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	ttl := time.Until(entry.DeleteAfter).Milliseconds()
+	if ttl <= 0 {
+		return rb.Delete(entry.Key)
+	}
+
+	_, err = rb.command("SET", rb.prefix+entry.Key, string(data), "PX", strconv.FormatInt(ttl, 10))
+	return err
+}
+
+func (rb *RedisBackend) Delete(key string) error {
+	_, err := rb.command("DEL", rb.prefix+key)
+	return err
+}
+
+func (rb *RedisBackend) Close() error {
+	rb.mutex.Lock()
+	defer rb.mutex.Unlock()
+
+	if rb.conn == nil {
+		return nil
+	}
+	err := rb.conn.Close()
+	rb.conn = nil
+	return err
+}
+
+// Выполняем команду. При сетевой ошибке один раз переподключаемся
+// (все используемые команды можно безопасно повторить)
+func (rb *RedisBackend) command(args ...string) (interface{}, error) {
+	rb.mutex.Lock()
+	defer rb.mutex.Unlock()
+
+	reply, err := rb.roundTrip(args)
+	if _, isServerErr := err.(redisError); err != nil && !isServerErr {
+		// Сервер мог закрыть простаивающее соединение: переподключаемся и повторяем
+		reply, err = rb.roundTrip(args)
+	}
+	return reply, err
+}
+
+func (rb *RedisBackend) roundTrip(args []string) (interface{}, error) {
+	if err := rb.connect(); err != nil {
+		return nil, err
+	}
+
+	rb.conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+	if err := writeRESP(rb.conn, args); err != nil {
+		rb.reset()
+		return nil, err
+	}
+
+	reply, err := readRESP(rb.reader)
+	if err != nil {
+		if _, isServerErr := err.(redisError); !isServerErr {
+			rb.reset()
+		}
+	}
+	return reply, err
+}
+
+// Подключаемся, авторизуемся и выбираем базу (вызывать под mutex)
+func (rb *RedisBackend) connect() error {
+	if rb.conn != nil {
+		return nil
+	}
+
+	conn, err := rb.dial()
+	if err != nil {
+		return err
+	}
+	rb.conn = conn
+	rb.reader = bufio.NewReader(conn)
+
+	var setup [][]string
+	if rb.password != "" {
+		setup = append(setup, []string{"AUTH", rb.password})
+	}
+	if rb.db != 0 {
+		setup = append(setup, []string{"SELECT", strconv.Itoa(rb.db)})
+	}
+
+	for _, args := range setup {
+		if err := writeRESP(conn, args); err != nil {
+			rb.reset()
+			return err
+		}
+		if _, err := readRESP(rb.reader); err != nil {
+			rb.reset()
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (rb *RedisBackend) reset() {
+	if rb.conn != nil {
+		rb.conn.Close()
+	}
+	rb.conn = nil
+	rb.reader = nil
+}
+
+// Ошибка, которую вернул сервер (ответ "-ERR ...")
+type redisError string
+
+func (e redisError) Error() string {
+	return "redis: " + string(e)
+}
+
+// Кодируем команду как массив bulk-строк
+func writeRESP(conn net.Conn, args []string) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+
+	_, err := conn.Write([]byte(b.String()))
+	return err
+}
+
+// Читаем один ответ: строки, числа, bulk-строки (nil для пустых) и массивы
+func readRESP(reader *bufio.Reader) (interface{}, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("пустой ответ RESP")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if size < 0 {
+			return nil, nil
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return nil, err
+		}
+		return string(data[:size]), nil
+	case '*':
+		count, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if count < 0 {
+			return nil, nil
+		}
+		items := make([]interface{}, 0, count)
+		for i := 0; i < count; i++ {
+			item, err := readRESP(reader)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("неизвестный тип ответа RESP: %q", line[0])
+	}
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Встроенный RESP-сервер: понимает SET (с PX), GET, DEL и SCAN с MATCH prefix*.
+// SCAN отдает ключи страницами по scanPage, чтобы проверить обход курсором
+type respStub struct {
+	mutex    sync.Mutex
+	data     map[string]string
+	ttls     map[string]time.Duration
+	scanPage int
+	commands []string
+}
+
+func newRESPStub() *respStub {
+	return &respStub{data: make(map[string]string), ttls: make(map[string]time.Duration), scanPage: 2}
+}
+
+func (s *respStub) dial() (net.Conn, error) {
+	client, server := net.Pipe()
+	go s.serve(server)
+	return client, nil
+}
+
+func (s *respStub) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+
+	for {
+		request, err := readRESP(reader)
+		if err != nil {
+			return
+		}
+		parts, _ := request.([]interface{})
+		args := make([]string, len(parts))
+		for i, part := range parts {
+			args[i], _ = part.(string)
+		}
+
+		if _, err := conn.Write([]byte(s.handle(args))); err != nil {
+			return
+		}
+	}
+}
+
+func (s *respStub) handle(args []string) string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if len(args) == 0 {
+		return "-ERR empty command\r\n"
+	}
+	s.commands = append(s.commands, strings.ToUpper(args[0]))
+
+	switch strings.ToUpper(args[0]) {
+	case "SET":
+		s.data[args[1]] = args[2]
+		if len(args) == 5 && strings.ToUpper(args[3]) == "PX" {
+			ms, _ := strconv.Atoi(args[4])
+			s.ttls[args[1]] = time.Duration(ms) * time.Millisecond
+		}
+		return "+OK\r\n"
+	case "GET":
+		value, exists := s.data[args[1]]
+		if !exists {
+			return "$-1\r\n"
+		}
+		return bulk(value)
+	case "DEL":
+		_, exists := s.data[args[1]]
+		delete(s.data, args[1])
+		delete(s.ttls, args[1])
+		if exists {
+			return ":1\r\n"
+		}
+		return ":0\r\n"
+	case "SCAN":
+		cursor, _ := strconv.Atoi(args[1])
+		prefix := ""
+		for i := 2; i+1 < len(args); i += 2 {
+			if strings.ToUpper(args[i]) == "MATCH" {
+				prefix = strings.TrimSuffix(args[i+1], "*")
+			}
+		}
+
+		var keys []string
+		for key := range s.data {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		end := cursor + s.scanPage
+		next := strconv.Itoa(end)
+		if end >= len(keys) {
+			end, next = len(keys), "0"
+		}
+
+		var page []string
+		for _, key := range keys[cursor:end] {
+			if strings.HasPrefix(key, prefix) {
+				page = append(page, bulk(key))
+			}
+		}
+		return fmt.Sprintf("*2\r\n%s*%d\r\n%s", bulk(next), len(page), strings.Join(page, ""))
+	default:
+		return "-ERR unknown command\r\n"
+	}
+}
+
+func bulk(value string) string {
+	return fmt.Sprintf("$%d\r\n%s\r\n", len(value), value)
+}
+
+func testEntry(key string, now time.Time) StoredEntry {
+	return StoredEntry{
+		Key:         key,
+		Value:       []byte(`{"steam_id":"` + key + `"}`),
+		CreatedAt:   now.Add(-time.Minute),
+		ExpiresAt:   now.Add(30 * time.Minute),
+		DeleteAfter: now.Add(2 * time.Hour),
+	}
+}
+
+// Записи после Load совпадают с сохраненными, включая все сроки
+func checkLoaded(t *testing.T, loaded []StoredEntry, want ...StoredEntry) {
+	t.Helper()
+
+	sort.Slice(loaded, func(i, j int) bool { return loaded[i].Key < loaded[j].Key })
+	if len(loaded) != len(want) {
+		t.Fatalf("загружено %d записей, ожидалось %d: %+v", len(loaded), len(want), loaded)
+	}
+	for i, entry := range loaded {
+		expected := want[i]
+		switch {
+		case entry.Key != expected.Key:
+			t.Errorf("ключ %q, ожидался %q", entry.Key, expected.Key)
+		case string(entry.Value) != string(expected.Value):
+			t.Errorf("%s: значение %s, ожидалось %s", entry.Key, entry.Value, expected.Value)
+		case !entry.CreatedAt.Equal(expected.CreatedAt):
+			t.Errorf("%s: CreatedAt %v, ожидалось %v", entry.Key, entry.CreatedAt, expected.CreatedAt)
+		case !entry.ExpiresAt.Equal(expected.ExpiresAt):
+			t.Errorf("%s: ExpiresAt %v, ожидалось %v", entry.Key, entry.ExpiresAt, expected.ExpiresAt)
+		case !entry.DeleteAfter.Equal(expected.DeleteAfter):
+			t.Errorf("%s: DeleteAfter %v, ожидалось %v", entry.Key, entry.DeleteAfter, expected.DeleteAfter)
+		}
+	}
+}
+
+func TestRedisBackend(t *testing.T) {
+	stub := newRESPStub()
+	backend := NewRedisBackendWithDialer(stub.dial, "inventory:")
+	defer backend.Close()
+
+	// Чужие ключи не должны попасть в Load
+	stub.data["prices:x"] = `{"key":"x"}`
+
+	now := time.Now()
+	var saved []StoredEntry
+	for _, key := range []string{"a", "b", "c", "d", "e"} {
+		entry := testEntry(key, now)
+		if err := backend.Save(entry); err != nil {
+			t.Fatalf("Save(%s): %v", key, err)
+		}
+		saved = append(saved, entry)
+	}
+
+	ttl := stub.ttls["inventory:a"]
+	if ttl <= time.Hour || ttl > 2*time.Hour {
+		t.Errorf("TTL записи %v, ожидалось около 2 ч (до DeleteAfter)", ttl)
+	}
+
+	if err := backend.Delete("c"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, exists := stub.data["inventory:c"]; exists {
+		t.Error("Delete не удалил ключ")
+	}
+
+	// Новое соединение, как после перезапуска
+	reloaded := NewRedisBackendWithDialer(stub.dial, "inventory:")
+	defer reloaded.Close()
+
+	loaded, err := reloaded.Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	checkLoaded(t, loaded, saved[0], saved[1], saved[3], saved[4])
+
+	scans := 0
+	for _, command := range stub.commands {
+		if command == "SCAN" {
+			scans++
+		}
+	}
+	if scans < 2 {
+		t.Errorf("SCAN вызван %d раз, ожидался обход нескольких страниц", scans)
+	}
+}
+
+func TestRedisBackendExpiredSaveDeletes(t *testing.T) {
+	stub := newRESPStub()
+	backend := NewRedisBackendWithDialer(stub.dial, "inventory:")
+	defer backend.Close()
+
+	now := time.Now()
+	if err := backend.Save(testEntry("a", now)); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	expired := testEntry("a", now)
+	expired.DeleteAfter = now.Add(-time.Second)
+	if err := backend.Save(expired); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	if _, exists := stub.data["inventory:a"]; exists {
+		t.Error("запись с истекшим DeleteAfter должна удаляться, а не сохраняться")
+	}
+}
+
+func TestFileBackendRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	backend, err := NewFileBackend(dir)
+	if err != nil {
+		t.Fatalf("NewFileBackend: %v", err)
+	}
+
+	now := time.Now()
+	a, b := testEntry("76561198000000001_730_2_5", now), testEntry("76561198000000002_570_2_5", now)
+	expired := testEntry("old", now)
+	expired.DeleteAfter = now.Add(-time.Second)
+
+	for _, entry := range []StoredEntry{a, b, expired} {
+		if err := backend.Save(entry); err != nil {
+			t.Fatalf("Save(%s): %v", entry.Key, err)
+		}
+	}
+
+	// Перезапись того же ключа заменяет файл
+	b.ExpiresAt = now.Add(45 * time.Minute)
+	if err := backend.Save(b); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	reloaded, err := NewFileBackend(dir)
+	if err != nil {
+		t.Fatalf("NewFileBackend: %v", err)
+	}
+	loaded, err := reloaded.Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	checkLoaded(t, loaded, a, b)
+
+	if err := reloaded.Delete(a.Key); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := reloaded.Delete("missing"); err != nil {
+		t.Errorf("удаление отсутствующей записи: %v", err)
+	}
+
+	loaded, err = reloaded.Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	checkLoaded(t, loaded, b)
+}
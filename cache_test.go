@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Хранилище в памяти, которое не отвечает, пока его не отпустят
+type slowBackend struct {
+	mutex   sync.Mutex
+	entries map[string]StoredEntry
+	release chan struct{}
+}
+
+func (b *slowBackend) Load() ([]StoredEntry, error) { return nil, nil }
+
+func (b *slowBackend) Save(entry StoredEntry) error {
+	<-b.release
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.entries[entry.Key] = entry
+	return nil
+}
+
+func (b *slowBackend) Delete(key string) error {
+	<-b.release
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	delete(b.entries, key)
+	return nil
+}
+
+func (b *slowBackend) Close() error { return nil }
+
+// Пока хранилище занято, операции не теряются: для каждого ключа
+// записывается последняя, в том числе удаление после вытеснения
+func TestCachePersistKeepsLatestOp(t *testing.T) {
+	backend := &slowBackend{entries: make(map[string]StoredEntry), release: make(chan struct{})}
+	cache := NewCache(CacheOptions[string]{TTL: time.Hour, StaleTTL: time.Hour, MaxEntries: 100, Backend: backend})
+
+	// Больше операций, чем помещалось в прежний буфер
+	for i := 0; i < 1000; i++ {
+		cache.Set(fmt.Sprintf("key%d", i%300), fmt.Sprintf("v%d", i))
+	}
+	cache.Delete("key99")
+
+	close(backend.release)
+	cache.Close()
+
+	if len(backend.entries) != 99 {
+		t.Fatalf("в хранилище %d записей, ожидалось 99 (последние 100 без удаленной)", len(backend.entries))
+	}
+	for i := 0; i < 99; i++ {
+		key := fmt.Sprintf("key%d", i)
+		entry, exists := backend.entries[key]
+		if !exists {
+			t.Fatalf("%s нет в хранилище", key)
+		}
+		if want := fmt.Sprintf(`"v%d"`, 900+i); string(entry.Value) != want {
+			t.Errorf("%s = %s, ожидалось %s", key, entry.Value, want)
+		}
+	}
+}
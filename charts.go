@@ -0,0 +1,194 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"log"
+	"math"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Размер картинок с графиками
+const (
+	chartWidth  = 900
+	chartHeight = 520
+)
+
+// Цвета секторов и столбцов
+var chartPalette = []color.RGBA{
+	{0x66, 0xc0, 0xf4, 0xff},
+	{0xa4, 0xd0, 0x07, 0xff},
+	{0xeb, 0x4b, 0x4b, 0xff},
+	{0xd3, 0x2c, 0xe6, 0xff},
+	{0xe4, 0xae, 0x39, 0xff},
+	{0x4b, 0x69, 0xff, 0xff},
+	{0x5e, 0x98, 0xd9, 0xff},
+	{0x8f, 0x98, 0xa0, 0xff},
+}
+
+// Интервалы гистограммы цен в рублях: [bounds[i], bounds[i+1])
+var histogramBounds = []float64{0, 10, 50, 100, 500, 1000, 5000, 10000, math.Inf(1)}
+
+// Круговая диаграмма стоимости по категориям с легендой
+func RenderCategoryChart(breakdown Breakdown) ([]byte, error) {
+	if breakdown.TotalValue <= 0 || len(breakdown.Categories) == 0 {
+		return nil, errors.New("нет данных для диаграммы")
+	}
+
+	canvas := image.NewRGBA(image.Rect(0, 0, chartWidth, chartHeight))
+	fillRect(canvas, canvas.Bounds(), colorBackground)
+
+	titleFace := newFace(true, 26)
+	labelFace := newFace(false, 17)
+	valueFace := newFace(true, 17)
+
+	drawText(canvas, titleFace, 24, 44, "Стоимость по категориям", colorText)
+
+	// Кольцо: для каждой точки круга находим сектор по углу от верхней точки по часовой стрелке
+	cx, cy, outer, inner := 240, 290, 190.0, 105.0
+	for y := cy - int(outer); y <= cy+int(outer); y++ {
+		for x := cx - int(outer); x <= cx+int(outer); x++ {
+			dx, dy := float64(x-cx), float64(y-cy)
+			distance := math.Hypot(dx, dy)
+			if distance > outer || distance < inner {
+				continue
+			}
+
+			angle := math.Atan2(dy, dx) + math.Pi/2
+			if angle < 0 {
+				angle += 2 * math.Pi
+			}
+			share := angle / (2 * math.Pi) * breakdown.TotalValue
+
+			cumulative := 0.0
+			for i, group := range breakdown.Categories {
+				cumulative += group.Value
+				if share <= cumulative || i == len(breakdown.Categories)-1 {
+					canvas.SetRGBA(x, y, chartPalette[i%len(chartPalette)])
+					break
+				}
+			}
+		}
+	}
+
+	total := formatRubles(breakdown.TotalValue)
+	drawText(canvas, valueFace, cx-textWidth(valueFace, total)/2, cy+6, total, colorText)
+
+	// Легенда справа
+	legendX, legendY := 480, 110
+	for i, group := range breakdown.Categories {
+		y := legendY + i*46
+		fillRect(canvas, image.Rect(legendX, y-14, legendX+18, y+4), chartPalette[i%len(chartPalette)])
+		drawText(canvas, labelFace, legendX+30, y, fitText(labelFace, group.Name, chartWidth-legendX-40), colorText)
+		detail := fmt.Sprintf("%.1f%% · %s · %d шт.", breakdown.percent(group.Value), formatRubles(group.Value), group.Count)
+		drawText(canvas, labelFace, legendX+30, y+21, fitText(labelFace, detail, chartWidth-legendX-40), colorMuted)
+	}
+
+	return encodePNG(canvas)
+}
+
+// Подпись интервала гистограммы
+func histogramLabel(i int) string {
+	low, high := histogramBounds[i], histogramBounds[i+1]
+	if math.IsInf(high, 1) {
+		return fmt.Sprintf("%s+", compactNumber(low))
+	}
+	return fmt.Sprintf("%s–%s", compactNumber(low), compactNumber(high))
+}
+
+// 5000 -> 5k
+func compactNumber(value float64) string {
+	if value >= 1000 {
+		return fmt.Sprintf("%gk", value/1000)
+	}
+	return fmt.Sprintf("%g", value)
+}
+
+// Гистограмма: сколько предметов в каждом ценовом интервале
+func RenderPriceHistogram(result *ScanResult) ([]byte, error) {
+	if len(result.Items) == 0 {
+		return nil, errors.New("нет данных для гистограммы")
+	}
+
+	counts := make([]int, len(histogramBounds)-1)
+	for _, item := range result.Items {
+		for i := range counts {
+			if item.PriceValue < histogramBounds[i+1] {
+				counts[i] += item.Quantity()
+				break
+			}
+		}
+	}
+
+	maxCount := 0
+	for _, count := range counts {
+		if count > maxCount {
+			maxCount = count
+		}
+	}
+
+	canvas := image.NewRGBA(image.Rect(0, 0, chartWidth, chartHeight))
+	fillRect(canvas, canvas.Bounds(), colorBackground)
+
+	titleFace := newFace(true, 26)
+	labelFace := newFace(false, 16)
+	valueFace := newFace(true, 16)
+
+	drawText(canvas, titleFace, 24, 44, "Распределение цен предметов, руб.", colorText)
+
+	left, right, top, bottom := 40, chartWidth-40, 90, chartHeight-60
+	fillRect(canvas, image.Rect(left, bottom, right, bottom+2), colorMuted)
+
+	slot := (right - left) / len(counts)
+	for i, count := range counts {
+		x := left + i*slot
+		label := histogramLabel(i)
+		drawText(canvas, labelFace, x+(slot-textWidth(labelFace, label))/2, bottom+26, label, colorMuted)
+
+		if count == 0 {
+			continue
+		}
+
+		barHeight := (bottom - top - 30) * count / maxCount
+		if barHeight < 2 {
+			barHeight = 2
+		}
+		bar := image.Rect(x+12, bottom-barHeight, x+slot-12, bottom)
+		fillRect(canvas, bar, chartPalette[0])
+
+		text := fmt.Sprintf("%d", count)
+		drawText(canvas, valueFace, x+(slot-textWidth(valueFace, text))/2, bar.Min.Y-8, text, colorText)
+	}
+
+	return encodePNG(canvas)
+}
+
+// Рисуем и отправляем графики альбомом (кнопка под отчетом)
+func (tb *TelegramBot) sendCharts(chatID int64, steamID, appID string) {
+	result, ok := tb.scanResult(chatID, steamID, appID)
+	if !ok {
+		return
+	}
+
+	categories, err := RenderCategoryChart(BuildBreakdown(result))
+	if err != nil {
+		tb.sendMessage(chatID, "❌ Недостаточно данных для графиков")
+		return
+	}
+	histogram, err := RenderPriceHistogram(result)
+	if err != nil {
+		tb.sendMessage(chatID, "❌ Недостаточно данных для графиков")
+		return
+	}
+
+	pie := tgbotapi.NewInputMediaPhoto(tgbotapi.FileBytes{Name: "categories.png", Bytes: categories})
+	pie.Caption = fmt.Sprintf("📊 Инвентарь %s (%s)", result.SteamID, getGameName(result.AppID))
+	bars := tgbotapi.NewInputMediaPhoto(tgbotapi.FileBytes{Name: "prices.png", Bytes: histogram})
+
+	if _, err := tb.bot.SendMediaGroup(tgbotapi.NewMediaGroup(chatID, []interface{}{pie, bars})); err != nil {
+		log.Printf("Ошибка отправки графиков: %v", err)
+	}
+}
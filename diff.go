@@ -0,0 +1,307 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Сколько строк показываем в каждом разделе /diff
+const diffSectionLimit = 10
+
+// Изменение одного предмета (или группы одинаковых предметов) между снимками
+type ItemChange struct {
+	Name           string
+	MarketHashName string
+	OldQuantity    int
+	NewQuantity    int
+	OldPrice       float64
+	NewPrice       float64
+}
+
+// Изменение стоимости за счет количества и цены
+func (c ItemChange) ValueChange() float64 {
+	return c.NewPrice*float64(c.NewQuantity) - c.OldPrice*float64(c.OldQuantity)
+}
+
+// Разница между двумя снимками инвентаря
+type SnapshotDiff struct {
+	From *Snapshot
+	To   *Snapshot
+
+	Added           []ItemChange // появились
+	Removed         []ItemChange // проданы, обменены или удалены
+	QuantityChanged []ItemChange // есть в обоих снимках, но в другом количестве
+	Repriced        []ItemChange // есть в обоих снимках, цена изменилась
+
+	ValueChange    float64
+	PriceEffect    float64 // изменение стоимости из-за цен на предметы, которые были и остались
+	HoldingsEffect float64 // изменение стоимости из-за появления, ухода и изменения количества предметов
+}
+
+// Изменение стоимости только за счет цены (при прежнем количестве)
+func (c ItemChange) PriceEffect() float64 {
+	return float64(c.OldQuantity) * (c.NewPrice - c.OldPrice)
+}
+
+// Нет ли изменений в составе инвентаря
+func (d SnapshotDiff) HoldingsUnchanged() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.QuantityChanged) == 0
+}
+
+// Сравниваем снимки. Предметы сопоставляются по asset ID, а оставшиеся -
+// по market_hash_name (asset ID меняется после обмена и возврата предмета)
+func DiffSnapshots(from, to *Snapshot) SnapshotDiff {
+	diff := SnapshotDiff{From: from, To: to, ValueChange: to.TotalValue - from.TotalValue}
+
+	oldByAsset := make(map[string]SnapshotItem)
+	for _, item := range from.Items {
+		if item.AssetID != "" {
+			oldByAsset[item.AssetID] = item
+		}
+	}
+
+	var matched []ItemChange
+	var newLeft []SnapshotItem
+	for _, item := range to.Items {
+		old, exists := oldByAsset[item.AssetID]
+		if !exists || item.AssetID == "" {
+			newLeft = append(newLeft, item)
+			continue
+		}
+		delete(oldByAsset, item.AssetID)
+		matched = append(matched, changeOf(old, item))
+	}
+
+	var oldLeft []SnapshotItem
+	for _, item := range from.Items {
+		if _, exists := oldByAsset[item.AssetID]; exists || item.AssetID == "" {
+			oldLeft = append(oldLeft, item)
+		}
+	}
+
+	// Несопоставленные предметы объединяем по названию
+	oldGroups, oldOrder := groupByName(oldLeft)
+	newGroups, newOrder := groupByName(newLeft)
+
+	for _, name := range newOrder {
+		group := newGroups[name]
+		if old, exists := oldGroups[name]; exists {
+			matched = append(matched, ItemChange{
+				Name:           group.Name,
+				MarketHashName: name,
+				OldQuantity:    old.Quantity,
+				NewQuantity:    group.Quantity,
+				OldPrice:       old.Price,
+				NewPrice:       group.Price,
+			})
+			continue
+		}
+		diff.Added = append(diff.Added, ItemChange{Name: group.Name, MarketHashName: name, NewQuantity: group.Quantity, NewPrice: group.Price})
+	}
+
+	for _, name := range oldOrder {
+		if _, exists := newGroups[name]; exists {
+			continue
+		}
+		old := oldGroups[name]
+		diff.Removed = append(diff.Removed, ItemChange{Name: old.Name, MarketHashName: name, OldQuantity: old.Quantity, OldPrice: old.Price})
+	}
+
+	// Разложение: new*newP - old*oldP = old*(newP-oldP) + (new-old)*newP
+	for _, change := range matched {
+		diff.PriceEffect += change.PriceEffect()
+		diff.HoldingsEffect += float64(change.NewQuantity-change.OldQuantity) * change.NewPrice
+
+		if change.NewQuantity != change.OldQuantity {
+			diff.QuantityChanged = append(diff.QuantityChanged, change)
+		}
+		if math.Abs(change.NewPrice-change.OldPrice) >= 0.01 {
+			diff.Repriced = append(diff.Repriced, change)
+		}
+	}
+	for _, change := range diff.Added {
+		diff.HoldingsEffect += change.ValueChange()
+	}
+	for _, change := range diff.Removed {
+		diff.HoldingsEffect += change.ValueChange()
+	}
+
+	sortByImpact(diff.Added)
+	sortByImpact(diff.Removed)
+	sortByImpact(diff.QuantityChanged)
+	sortByPriceEffect(diff.Repriced)
+
+	return diff
+}
+
+func changeOf(old, item SnapshotItem) ItemChange {
+	return ItemChange{
+		Name:           item.Name,
+		MarketHashName: item.MarketHashName,
+		OldQuantity:    old.Quantity,
+		NewQuantity:    item.Quantity,
+		OldPrice:       old.Price,
+		NewPrice:       item.Price,
+	}
+}
+
+// Объединяем предметы с одинаковым market_hash_name, сохраняя порядок появления
+func groupByName(items []SnapshotItem) (map[string]SnapshotItem, []string) {
+	groups := make(map[string]SnapshotItem)
+	var order []string
+
+	for _, item := range items {
+		name := item.MarketHashName
+		if name == "" {
+			name = item.Name
+		}
+
+		group, exists := groups[name]
+		if !exists {
+			order = append(order, name)
+			group = item
+			group.Quantity = 0
+		}
+		group.Quantity += item.Quantity
+		groups[name] = group
+	}
+
+	return groups, order
+}
+
+func sortByImpact(changes []ItemChange) {
+	sort.SliceStable(changes, func(i, j int) bool {
+		return math.Abs(changes[i].ValueChange()) > math.Abs(changes[j].ValueChange())
+	})
+}
+
+func sortByPriceEffect(changes []ItemChange) {
+	sort.SliceStable(changes, func(i, j int) bool {
+		return math.Abs(changes[i].PriceEffect()) > math.Abs(changes[j].PriceEffect())
+	})
+}
+
+// Текст сравнения снимков
+func (d SnapshotDiff) Format() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "🔀 *Изменения инвентаря %s* (%s)\n", d.To.SteamID, getGameName(d.To.AppID))
+	fmt.Fprintf(&b, "%s → %s\n\n", d.From.TakenAt.Format("02.01.2006 15:04"), d.To.TakenAt.Format("02.01.2006 15:04"))
+
+	fmt.Fprintf(&b, "💵 Стоимость: %.2f → %.2f ₽ (%+.2f ₽, %s)\n", d.From.TotalValue, d.To.TotalValue, d.ValueChange, formatChange(d.From.TotalValue, d.To.TotalValue))
+	fmt.Fprintf(&b, "• Эффект цен: %+.2f ₽\n", d.PriceEffect)
+	fmt.Fprintf(&b, "• Эффект состава: %+.2f ₽\n", d.HoldingsEffect)
+
+	writeSection(&b, "🆕 Новые предметы", d.Added, diffSectionLimit, func(c ItemChange) string {
+		return fmt.Sprintf("%s × %d (%.2f ₽)", escapeMarkdown(c.Name), c.NewQuantity, c.ValueChange())
+	})
+	writeSection(&b, "📤 Ушли из инвентаря", d.Removed, diffSectionLimit, func(c ItemChange) string {
+		return fmt.Sprintf("%s × %d (%.2f ₽)", escapeMarkdown(c.Name), c.OldQuantity, -c.ValueChange())
+	})
+	writeSection(&b, "🔢 Изменилось количество", d.QuantityChanged, diffSectionLimit, func(c ItemChange) string {
+		return fmt.Sprintf("%s: %d → %d", escapeMarkdown(c.Name), c.OldQuantity, c.NewQuantity)
+	})
+	writeSection(&b, "💱 Изменились цены", d.Repriced, diffSectionLimit, func(c ItemChange) string {
+		return fmt.Sprintf("%s: %.2f → %.2f ₽ (%s)", escapeMarkdown(c.Name), c.OldPrice, c.NewPrice, formatChange(c.OldPrice, c.NewPrice))
+	})
+
+	if d.HoldingsUnchanged() && len(d.Repriced) == 0 {
+		b.WriteString("\nИзменений нет")
+	}
+	if d.From.Partial || d.To.Partial {
+		b.WriteString("\n⚠️ Один из снимков неполный: часть изменений может объясняться этим")
+	}
+
+	return b.String()
+}
+
+// Раздел списка изменений: не больше limit строк
+func writeSection(b *strings.Builder, title string, changes []ItemChange, limit int, line func(ItemChange) string) {
+	if len(changes) == 0 {
+		return
+	}
+
+	fmt.Fprintf(b, "\n*%s (%d):*\n", title, len(changes))
+	for i, change := range changes {
+		if i == limit {
+			fmt.Fprintf(b, "…и еще %d\n", len(changes)-limit)
+			break
+		}
+		b.WriteString("• " + line(change) + "\n")
+	}
+}
+
+// Период сравнения вида 7d или 24h
+func parsePeriod(text string) (time.Duration, bool) {
+	if len(text) < 2 {
+		return 0, false
+	}
+
+	value, err := strconv.Atoi(text[:len(text)-1])
+	if err != nil || value <= 0 {
+		return 0, false
+	}
+
+	switch text[len(text)-1] {
+	case 'd':
+		return time.Duration(value) * 24 * time.Hour, true
+	case 'h':
+		return time.Duration(value) * time.Hour, true
+	default:
+		return 0, false
+	}
+}
+
+// Последний снимок, сделанный не позже момента before
+func snapshotBefore(snapshots []*Snapshot, before time.Time) (*Snapshot, bool) {
+	for i := len(snapshots) - 1; i >= 0; i-- {
+		if !snapshots[i].TakenAt.After(before) {
+			return snapshots[i], true
+		}
+	}
+	return nil, false
+}
+
+func (tb *TelegramBot) handleDiffCommand(chatID int64, text string) {
+	parts := strings.Fields(text)
+	if len(parts) < 2 {
+		tb.sendMessage(chatID, "Использование: /diff <steam\\_id> [app\\_id] [7d|24h]")
+		return
+	}
+
+	steamID, ok := tb.resolveInput(chatID, parts[1])
+	if !ok {
+		return
+	}
+
+	appID := "730" // CS:GO по умолчанию
+	var period time.Duration
+	for _, arg := range parts[2:] {
+		if value, isPeriod := parsePeriod(arg); isPeriod {
+			period = value
+		} else {
+			appID = arg
+		}
+	}
+
+	snapshots := tb.snapshots.List(steamID, appID)
+	if len(snapshots) < 2 {
+		tb.sendMessage(chatID, "📭 Для сравнения нужно хотя бы два снимка. Снимок сохраняется после каждого сканирования.")
+		return
+	}
+
+	to := snapshots[len(snapshots)-1]
+	from := snapshots[len(snapshots)-2]
+	if period > 0 {
+		var found bool
+		from, found = snapshotBefore(snapshots[:len(snapshots)-1], to.TakenAt.Add(-period))
+		if !found {
+			from = snapshots[0]
+		}
+	}
+
+	tb.sendMessage(chatID, DiffSnapshots(from, to).Format())
+}
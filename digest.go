@@ -0,0 +1,398 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata" // часовые пояса не зависят от системной базы в контейнере
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Вид задач планировщика для сводок
+const digestKind = "digest"
+
+const (
+	digestDaily  = "daily"
+	digestWeekly = "weekly" // по понедельникам
+
+	defaultTimezone = "Europe/Moscow"
+	// Сколько изменений цен, новых и ушедших предметов показываем в сводке
+	digestTopItems = 5
+
+	// Сколько срабатываний оповещения или правила храним для сводки:
+	// не старше самого длинного периода сводки (см. runDigest)
+	maxTriggers      = 20
+	triggerRetention = 14 * 24 * time.Hour
+
+	// Через сколько повторить сводку, которую не удалось отправить
+	digestRetryDelay = 10 * time.Minute
+)
+
+// Срабатывание оповещения или правила
+type Trigger struct {
+	At    time.Time `json:"at"`
+	Value float64   `json:"value"` // цена предмета или стоимость профиля
+
+	// Для правил - профиль, на котором оно сработало
+	SteamID string `json:"steam_id,omitempty"`
+	AppID   string `json:"app_id,omitempty"`
+}
+
+// Добавляем срабатывание в историю, отбрасывая старые
+func appendTrigger(triggers []Trigger, trigger Trigger) []Trigger {
+	kept := make([]Trigger, 0, len(triggers)+1)
+	for _, old := range triggers {
+		if trigger.At.Sub(old.At) < triggerRetention {
+			kept = append(kept, old)
+		}
+	}
+	kept = append(kept, trigger)
+	if len(kept) > maxTriggers {
+		kept = kept[len(kept)-maxTriggers:]
+	}
+	return kept
+}
+
+// Данные задачи сводки (одна сводка на пользователя, приходит в чат, где ее настроили)
+type DigestPayload struct {
+	Period   string    `json:"period"`
+	Hour     int       `json:"hour"`
+	Minute   int       `json:"minute"`
+	Timezone string    `json:"timezone"`
+	LastSent time.Time `json:"last_sent"`
+}
+
+func digestID(userID int64) string {
+	return fmt.Sprintf("digest_%d", userID)
+}
+
+// Часовой пояс пользователя из настроек его сводки
+func (tb *TelegramBot) userLocation(userID int64) *time.Location {
+	if task, exists := tb.scheduler.Get(digestID(userID)); exists {
+		if digest, ok := taskPayload[DigestPayload](task); ok {
+			return mustLocation(digest.Timezone)
+		}
+	}
+	return mustLocation(defaultTimezone)
+}
+
+// Смещение от UTC: +3, UTC+3, GMT-5, +05:30
+var utcOffsetPattern = regexp.MustCompile(`^(?:UTC|GMT)?([+-])(\d{1,2})(?::(\d{2}))?$`)
+
+// Часовой пояс по имени (Europe/Moscow) или смещению от UTC
+func parseTimezone(name string) (*time.Location, error) {
+	if matches := utcOffsetPattern.FindStringSubmatch(strings.ToUpper(name)); matches != nil {
+		hours, _ := strconv.Atoi(matches[2])
+		minutes, _ := strconv.Atoi(matches[3])
+		if hours > 14 || minutes >= 60 {
+			return nil, fmt.Errorf("неверное смещение: %s", name)
+		}
+		offset := hours*3600 + minutes*60
+		if matches[1] == "-" {
+			offset = -offset
+		}
+		return time.FixedZone(strings.ToUpper(name), offset), nil
+	}
+
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("неизвестный часовой пояс: %s", name)
+	}
+	return location, nil
+}
+
+// Время вида 09:30
+func parseClock(text string) (hour, minute int, ok bool) {
+	clock, err := time.Parse("15:04", text)
+	if err != nil {
+		return 0, 0, false
+	}
+	return clock.Hour(), clock.Minute(), true
+}
+
+// Длительность периода сводки
+func (d DigestPayload) period() time.Duration {
+	if d.Period == digestWeekly {
+		return 7 * 24 * time.Hour
+	}
+	return 24 * time.Hour
+}
+
+// Следующее время отправки после now в часовом поясе пользователя
+func (d DigestPayload) nextRun(now time.Time) time.Time {
+	location, err := parseTimezone(d.Timezone)
+	if err != nil {
+		location = time.UTC
+	}
+
+	local := now.In(location)
+	next := time.Date(local.Year(), local.Month(), local.Day(), d.Hour, d.Minute, 0, 0, location)
+	if d.Period == digestWeekly {
+		next = next.AddDate(0, 0, (int(time.Monday)-int(next.Weekday())+7)%7)
+	}
+	for !next.After(now) {
+		if d.Period == digestWeekly {
+			next = next.AddDate(0, 0, 7)
+		} else {
+			next = next.AddDate(0, 0, 1)
+		}
+	}
+	return next
+}
+
+// Расписание словами: "ежедневно в 09:00 (Europe/Moscow)"
+func (d DigestPayload) Describe() string {
+	period := "ежедневно"
+	if d.Period == digestWeekly {
+		period = "по понедельникам"
+	}
+	return fmt.Sprintf("%s в %02d:%02d (%s)", period, d.Hour, d.Minute, d.Timezone)
+}
+
+func (tb *TelegramBot) handleDigestCommand(chatID, userID int64, text string) {
+	parts := strings.Fields(text)
+	id := digestID(userID)
+	usage := "Использование: /digest daily|weekly ЧЧ:ММ [часовой пояс]\nПример: /digest daily 09:00 Europe/Moscow\nОтключить: /digest off"
+
+	if len(parts) < 2 {
+		task, exists := tb.scheduler.Get(id)
+		if !exists {
+			tb.sendMessage(chatID, "📰 Сводка не настроена.\n"+usage)
+			return
+		}
+		payload, ok := taskPayload[DigestPayload](task)
+		if !ok {
+			return
+		}
+		tb.sendMessage(chatID, fmt.Sprintf("📰 Сводка: %s\nСледующая: %s\n\n%s", payload.Describe(),
+			task.NextRun.In(mustLocation(payload.Timezone)).Format("02.01.2006 15:04"), usage))
+		return
+	}
+
+	if parts[1] == "off" {
+		if tb.scheduler.Remove(id) {
+			tb.sendMessage(chatID, "✅ Сводка отключена")
+		} else {
+			tb.sendMessage(chatID, "Сводка и так не настроена")
+		}
+		return
+	}
+
+	if (parts[1] != digestDaily && parts[1] != digestWeekly) || len(parts) < 3 {
+		tb.sendMessage(chatID, usage)
+		return
+	}
+
+	hour, minute, ok := parseClock(parts[2])
+	if !ok {
+		tb.sendMessage(chatID, "❌ Время в формате ЧЧ:ММ, например 09:00\n\n"+usage)
+		return
+	}
+
+	// Часовой пояс по умолчанию - из прежней настройки пользователя
+	timezone := defaultTimezone
+	if task, exists := tb.scheduler.Get(id); exists {
+		if previous, ok := taskPayload[DigestPayload](task); ok {
+			timezone = previous.Timezone
+		}
+	}
+	if len(parts) > 3 {
+		timezone = parts[3]
+	}
+	location, err := parseTimezone(timezone)
+	if err != nil {
+		tb.sendMessage(chatID, "❌ "+escapeMarkdown(err.Error())+". Укажите пояс вроде Europe/Moscow или смещение вроде UTC+3")
+		return
+	}
+	timezone = location.String()
+
+	payload := DigestPayload{Period: parts[1], Hour: hour, Minute: minute, Timezone: timezone}
+	task := newTask(id, digestKind, chatID, userID, payload)
+	task.NextRun = payload.nextRun(time.Now())
+	tb.scheduler.Add(task)
+
+	tb.sendMessage(chatID, fmt.Sprintf("📰 Сводка настроена: %s\nПервая придет сюда %s", payload.Describe(), task.NextRun.In(location).Format("02.01.2006 15:04")))
+}
+
+// Часовой пояс, сохраненный в задаче (UTC, если он больше не разбирается)
+func mustLocation(name string) *time.Location {
+	location, err := parseTimezone(name)
+	if err != nil {
+		return time.UTC
+	}
+	return location
+}
+
+// Отправляем сводку и планируем следующую
+func (tb *TelegramBot) runDigest(task ScheduledTask) time.Time {
+	payload, ok := taskPayload[DigestPayload](task)
+	if !ok {
+		return time.Time{}
+	}
+
+	now := time.Now()
+	since := payload.LastSent
+	if since.IsZero() || now.Sub(since) > 2*payload.period() {
+		since = now.Add(-payload.period())
+	}
+
+	msg := tgbotapi.NewMessage(task.ChatID, tb.buildDigest(task.UserID, payload, since))
+	msg.ParseMode = "Markdown"
+	if _, err := tb.bot.Send(msg); err != nil {
+		log.Printf("Ошибка отправки сводки: %v", err)
+		if isMessageGone(err) {
+			// Бота убрали из чата: сводку отправлять некуда
+			return time.Time{}
+		}
+		// Период сводки не сдвигаем: следующая попытка охватит и его
+		return now.Add(digestRetryDelay)
+	}
+
+	tb.scheduler.Update(task.ID, func(current *ScheduledTask) bool {
+		if updated, ok := taskPayload[DigestPayload](*current); ok {
+			updated.LastSent = now
+			current.setPayload(updated)
+		}
+		return true
+	})
+
+	return payload.nextRun(now)
+}
+
+// Текст сводки за период с момента since: стоимость профилей, за которыми следит
+// пользователь, самые заметные изменения цен, новые и ушедшие предметы,
+// сработавшие оповещения и правила пользователя
+func (tb *TelegramBot) buildDigest(userID int64, payload DigestPayload, since time.Time) string {
+	location := mustLocation(payload.Timezone)
+
+	var b strings.Builder
+	title := "Ежедневная сводка"
+	if payload.Period == digestWeekly {
+		title = "Еженедельная сводка"
+	}
+	fmt.Fprintf(&b, "📰 *%s*\n%s - %s\n", title, since.In(location).Format("02.01 15:04"), time.Now().In(location).Format("02.01 15:04"))
+
+	// Один профиль может быть под наблюдением в нескольких чатах
+	var profiles []WatchPayload
+	seen := make(map[string]bool)
+	for _, watch := range tb.userWatches(userID) {
+		watched, ok := taskPayload[WatchPayload](watch)
+		if !ok || seen[profileKey(watched.SteamID, watched.AppID)] {
+			continue
+		}
+		seen[profileKey(watched.SteamID, watched.AppID)] = true
+		profiles = append(profiles, watched)
+	}
+
+	var movers, added, removed []ItemChange
+	if len(profiles) == 0 {
+		b.WriteString("\nВы не следите ни за одним профилем. Добавьте: /watch <steam\\_id>\n")
+	} else {
+		b.WriteString("\n💼 *Профили:*\n")
+	}
+
+	total := 0.0
+	for _, watched := range profiles {
+		snapshots := tb.snapshots.List(watched.SteamID, watched.AppID)
+		if len(snapshots) == 0 {
+			fmt.Fprintf(&b, "• %s (%s): снимков пока нет\n", watched.SteamID, getGameName(watched.AppID))
+			continue
+		}
+
+		latest := snapshots[len(snapshots)-1]
+		total += latest.TotalValue
+
+		from, found := snapshotBefore(snapshots, since)
+		if !found {
+			from = snapshots[0]
+		}
+		if from == latest {
+			fmt.Fprintf(&b, "• %s (%s): %.2f ₽\n", watched.SteamID, getGameName(watched.AppID), latest.TotalValue)
+			continue
+		}
+
+		diff := DiffSnapshots(from, latest)
+		fmt.Fprintf(&b, "• %s (%s): %.2f ₽ (%+.2f ₽, %s)\n", watched.SteamID, getGameName(watched.AppID),
+			latest.TotalValue, diff.ValueChange, formatChange(from.TotalValue, latest.TotalValue))
+
+		movers = append(movers, diff.Repriced...)
+		added = append(added, diff.Added...)
+		removed = append(removed, diff.Removed...)
+	}
+	if len(profiles) > 1 {
+		fmt.Fprintf(&b, "Итого: %.2f ₽\n", total)
+	}
+
+	sortByPriceEffect(movers)
+	sortByImpact(added)
+	sortByImpact(removed)
+
+	writeSection(&b, "📈 Самые заметные изменения цен", movers, digestTopItems, func(c ItemChange) string {
+		return fmt.Sprintf("%s: %.2f → %.2f ₽ (%s)", escapeMarkdown(c.Name), c.OldPrice, c.NewPrice, formatChange(c.OldPrice, c.NewPrice))
+	})
+	writeSection(&b, "🆕 Новые предметы", added, digestTopItems, func(c ItemChange) string {
+		return fmt.Sprintf("%s × %d (%.2f ₽)", escapeMarkdown(c.Name), c.NewQuantity, c.ValueChange())
+	})
+	writeSection(&b, "📤 Ушли из инвентаря", removed, digestTopItems, func(c ItemChange) string {
+		return fmt.Sprintf("%s × %d (%.2f ₽)", escapeMarkdown(c.Name), c.OldQuantity, -c.ValueChange())
+	})
+
+	var alerts []digestEvent
+	for _, task := range tb.scheduler.List(alertKind, func(task ScheduledTask) bool { return task.UserID == userID }) {
+		alert, ok := taskPayload[AlertPayload](task)
+		if !ok {
+			continue
+		}
+		for _, trigger := range alert.Triggers {
+			if trigger.At.After(since) {
+				alerts = append(alerts, digestEvent{At: trigger.At, Line: fmt.Sprintf("%s: %s (%.2f ₽, %s)", escapeMarkdown(alert.MarketHashName),
+					alert.Describe(), trigger.Value, trigger.At.In(location).Format("02.01 15:04"))})
+			}
+		}
+	}
+	writeEvents(&b, "🔔 Сработавшие оповещения", alerts, digestTopItems)
+
+	var rules []digestEvent
+	for _, rule := range tb.rules.ListByUser(userID) {
+		for _, trigger := range rule.Triggers {
+			if trigger.At.After(since) {
+				rules = append(rules, digestEvent{At: trigger.At, Line: fmt.Sprintf("`%s`: %s (%s), %.2f ₽, %s", rule.Text, trigger.SteamID,
+					getGameName(trigger.AppID), trigger.Value, trigger.At.In(location).Format("02.01 15:04"))})
+			}
+		}
+	}
+	writeEvents(&b, "📐 Сработавшие правила", rules, digestTopItems)
+
+	return b.String()
+}
+
+// Строка раздела сводки о срабатывании
+type digestEvent struct {
+	At   time.Time
+	Line string
+}
+
+// Раздел сводки со срабатываниями: сначала последние, не больше limit строк
+func writeEvents(b *strings.Builder, title string, events []digestEvent, limit int) {
+	if len(events) == 0 {
+		return
+	}
+
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].At.After(events[j].At)
+	})
+
+	fmt.Fprintf(b, "\n*%s (%d):*\n", title, len(events))
+	for i, event := range events {
+		if i == limit {
+			fmt.Fprintf(b, "…и еще %d\n", len(events)-limit)
+			break
+		}
+		b.WriteString("• " + event.Line + "\n")
+	}
+}
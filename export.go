@@ -0,0 +1,325 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"html/template"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Версия схемы JSON-экспорта. Увеличивается при несовместимых изменениях полей
+const exportSchemaVersion = 1
+
+// Форматы экспорта в порядке кнопок
+var exportFormats = []string{"csv", "json", "xlsx", "html"}
+
+// Строка экспорта: один предмет (стопка) инвентаря
+type ExportRow struct {
+	AssetID        string  `json:"asset_id"`
+	Name           string  `json:"name"`
+	MarketHashName string  `json:"market_hash_name"`
+	Type           string  `json:"type"`
+	Quantity       int     `json:"quantity"`
+	Price          float64 `json:"price"`
+	Total          float64 `json:"total"`
+	PriceSource    string  `json:"price_source"`
+	Tradable       bool    `json:"tradable"`
+}
+
+// Документ JSON-экспорта
+type ExportDocument struct {
+	Schema     string      `json:"schema"`
+	Version    int         `json:"version"`
+	SteamID    string      `json:"steam_id"`
+	AppID      string      `json:"app_id"`
+	Game       string      `json:"game"`
+	Currency   string      `json:"currency"`
+	ScannedAt  time.Time   `json:"scanned_at"`
+	Partial    bool        `json:"partial"`
+	TotalValue float64     `json:"total_value"`
+	Items      []ExportRow `json:"items"`
+}
+
+// Откуда взята цена предмета
+func priceSource(item InventoryItem) string {
+	if strings.Contains(item.Price, "(lowest)") {
+		return "steam_market_lowest"
+	}
+	return "steam_market"
+}
+
+// Строки экспорта в порядке убывания цены
+func exportRows(result *ScanResult) []ExportRow {
+	items := result.ItemsByPrice()
+	rows := make([]ExportRow, len(items))
+
+	for i, item := range items {
+		rows[i] = ExportRow{
+			AssetID:        item.AssetID,
+			Name:           item.Name,
+			MarketHashName: item.MarketHashName,
+			Type:           item.Type,
+			Quantity:       item.Quantity(),
+			Price:          item.PriceValue,
+			Total:          item.Value(),
+			PriceSource:    priceSource(item),
+			Tradable:       item.Tradable,
+		}
+	}
+
+	return rows
+}
+
+// Заголовки табличных форматов
+var exportHeader = []string{"asset_id", "name", "market_hash_name", "type", "quantity", "price_rub", "total_rub", "price_source", "tradable"}
+
+func (row ExportRow) cells() []string {
+	return []string{
+		row.AssetID,
+		row.Name,
+		row.MarketHashName,
+		row.Type,
+		strconv.Itoa(row.Quantity),
+		strconv.FormatFloat(row.Price, 'f', 2, 64),
+		strconv.FormatFloat(row.Total, 'f', 2, 64),
+		row.PriceSource,
+		strconv.FormatBool(row.Tradable),
+	}
+}
+
+// Собираем файл экспорта в формате format
+func ExportScanResult(result *ScanResult, format string) ([]byte, error) {
+	switch format {
+	case "csv":
+		return exportCSV(result)
+	case "json":
+		return exportJSON(result)
+	case "xlsx":
+		return exportXLSX(result)
+	case "html":
+		return exportHTML(result)
+	default:
+		return nil, fmt.Errorf("неизвестный формат экспорта: %s", format)
+	}
+}
+
+func exportCSV(result *ScanResult) ([]byte, error) {
+	var buf bytes.Buffer
+	// BOM, чтобы Excel открыл UTF-8 с кириллицей без настройки импорта
+	buf.WriteString("\uFEFF")
+
+	writer := csv.NewWriter(&buf)
+	writer.Write(exportHeader)
+	for _, row := range exportRows(result) {
+		writer.Write(row.cells())
+	}
+	writer.Flush()
+
+	return buf.Bytes(), writer.Error()
+}
+
+func exportJSON(result *ScanResult) ([]byte, error) {
+	document := ExportDocument{
+		Schema:     "steam-inventory-export",
+		Version:    exportSchemaVersion,
+		SteamID:    result.SteamID,
+		AppID:      result.AppID,
+		Game:       getGameName(result.AppID),
+		Currency:   "RUB",
+		ScannedAt:  result.ScannedAt,
+		Partial:    result.Partial(),
+		TotalValue: BuildReport(result).TotalValue,
+		Items:      exportRows(result),
+	}
+
+	return json.MarshalIndent(document, "", "  ")
+}
+
+// XLSX собираем вручную: это zip с несколькими XML-файлами (Office Open XML)
+func exportXLSX(result *ScanResult) ([]byte, error) {
+	var sheet strings.Builder
+	sheet.WriteString(xml.Header)
+	sheet.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+
+	writeRow := func(number int, cells []string, numeric map[int]bool) {
+		fmt.Fprintf(&sheet, `<row r="%d">`, number)
+		for i, value := range cells {
+			ref := fmt.Sprintf("%c%d", 'A'+i, number)
+			if numeric[i] {
+				fmt.Fprintf(&sheet, `<c r="%s"><v>%s</v></c>`, ref, value)
+				continue
+			}
+			fmt.Fprintf(&sheet, `<c r="%s" t="inlineStr"><is><t>%s</t></is></c>`, ref, xmlEscape(value))
+		}
+		sheet.WriteString(`</row>`)
+	}
+
+	writeRow(1, exportHeader, nil)
+	numeric := map[int]bool{4: true, 5: true, 6: true}
+	for i, row := range exportRows(result) {
+		writeRow(i+2, row.cells(), numeric)
+	}
+	sheet.WriteString(`</sheetData></worksheet>`)
+
+	files := []struct {
+		name    string
+		content string
+	}{
+		{"[Content_Types].xml", xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+			`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+			`<Default Extension="xml" ContentType="application/xml"/>` +
+			`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+			`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+			`</Types>`},
+		{"_rels/.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+			`</Relationships>`},
+		{"xl/workbook.xml", xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			`<sheets><sheet name="Inventory" sheetId="1" r:id="rId1"/></sheets></workbook>`},
+		{"xl/_rels/workbook.xml.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+			`</Relationships>`},
+		{"xl/worksheets/sheet1.xml", sheet.String()},
+	}
+
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	for _, file := range files {
+		writer, err := archive.Create(file.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := writer.Write([]byte(file.content)); err != nil {
+			return nil, err
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func xmlEscape(text string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(text))
+	return buf.String()
+}
+
+var exportHTMLTemplate = template.Must(template.New("export").Funcs(template.FuncMap{
+	"inc": func(i int) int { return i + 1 },
+}).Parse(`<!DOCTYPE html>
+<html lang="ru">
+<head>
+<meta charset="utf-8">
+<title>Инвентарь {{.SteamID}} ({{.Game}})</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Roboto, sans-serif; margin: 2em; color: #1b2838; }
+table { border-collapse: collapse; width: 100%; }
+th, td { padding: 6px 10px; border-bottom: 1px solid #dde3ea; text-align: left; }
+th { background: #1b2838; color: #fff; }
+td.num { text-align: right; white-space: nowrap; }
+tr.locked td { color: #8a96a3; }
+</style>
+</head>
+<body>
+<h1>Инвентарь {{.SteamID}}</h1>
+<p>Игра: {{.Game}} · Сканирование: {{.ScannedAt.Format "02.01.2006 15:04"}} · Предметов с ценой: {{len .Items}} · Общая стоимость: <b>{{printf "%.2f" .TotalValue}} ₽</b>{{if .Partial}} · неполные данные{{end}}</p>
+<table>
+<tr><th>#</th><th>Предмет</th><th>Тип</th><th>Кол-во</th><th>Цена, ₽</th><th>Сумма, ₽</th><th>Источник цены</th><th>Обмен</th><th>Asset ID</th></tr>
+{{range $i, $row := .Items}}<tr{{if not $row.Tradable}} class="locked"{{end}}><td>{{inc $i}}</td><td>{{$row.Name}}</td><td>{{$row.Type}}</td><td class="num">{{$row.Quantity}}</td><td class="num">{{printf "%.2f" $row.Price}}</td><td class="num">{{printf "%.2f" $row.Total}}</td><td>{{$row.PriceSource}}</td><td>{{if $row.Tradable}}да{{else}}нет{{end}}</td><td>{{$row.AssetID}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+func exportHTML(result *ScanResult) ([]byte, error) {
+	document := ExportDocument{
+		SteamID:    result.SteamID,
+		AppID:      result.AppID,
+		Game:       getGameName(result.AppID),
+		ScannedAt:  result.ScannedAt,
+		Partial:    result.Partial(),
+		TotalValue: BuildReport(result).TotalValue,
+		Items:      exportRows(result),
+	}
+
+	var buf bytes.Buffer
+	if err := exportHTMLTemplate.Execute(&buf, document); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (tb *TelegramBot) handleExportCommand(chatID int64, text string) {
+	parts := strings.Fields(text)
+	if len(parts) < 2 {
+		tb.sendMessage(chatID, "Использование: /export <формат>\nФорматы: "+strings.Join(exportFormats, ", "))
+		return
+	}
+
+	format := strings.ToLower(parts[1])
+	if !isExportFormat(format) {
+		tb.sendMessage(chatID, "❌ Неизвестный формат. Доступны: "+strings.Join(exportFormats, ", "))
+		return
+	}
+
+	result, ok := tb.lastScan(chatID)
+	if !ok {
+		return
+	}
+
+	tb.sendExport(chatID, result, format)
+}
+
+func isExportFormat(format string) bool {
+	for _, known := range exportFormats {
+		if format == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Предлагаем выбрать формат экспорта (кнопка под отчетом)
+func (tb *TelegramBot) sendExportFormats(chatID int64, steamID, appID string) {
+	var buttons []tgbotapi.InlineKeyboardButton
+	for _, format := range exportFormats {
+		data := fmt.Sprintf("exportfile_%s_%s_%s", format, steamID, appID)
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardButtonData(strings.ToUpper(format), data))
+	}
+
+	msg := tgbotapi.NewMessage(chatID, "📤 Выберите формат экспорта:")
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(buttons)
+
+	if _, err := tb.bot.Send(msg); err != nil {
+		log.Printf("Ошибка отправки сообщения: %v", err)
+	}
+}
+
+// Отправляем файл экспорта документом
+func (tb *TelegramBot) sendExport(chatID int64, result *ScanResult, format string) {
+	data, err := ExportScanResult(result, format)
+	if err != nil {
+		log.Printf("Ошибка экспорта: %v", err)
+		tb.sendMessage(chatID, "❌ Не удалось подготовить файл экспорта")
+		return
+	}
+
+	name := fmt.Sprintf("inventory_%s_%s_%s.%s", result.SteamID, result.AppID, result.ScannedAt.Format("20060102_1504"), format)
+	document := tgbotapi.NewDocument(chatID, tgbotapi.FileBytes{Name: name, Bytes: data})
+	document.Caption = fmt.Sprintf("📤 %s: %d предметов", getGameName(result.AppID), len(result.Items))
+
+	if _, err := tb.bot.Send(document); err != nil {
+		log.Printf("Ошибка отправки документа: %v", err)
+	}
+}
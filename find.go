@@ -0,0 +1,231 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Сколько найденных строк показываем в ответе на /find
+const findResultsLimit = 20
+
+// Фильтр предметов для /find. Все условия должны выполняться одновременно
+type ItemFilter struct {
+	Names    []string // подстроки названия
+	Type     string
+	Rarity   string
+	Exterior string
+	Tradable *bool
+	MinPrice *float64
+	MaxPrice *float64
+}
+
+var pricePattern = regexp.MustCompile(`^(?:price|цена)(<=|>=|<|>|=)(\d+(?:[.,]\d+)?)$`)
+
+// Разбираем запрос вида: type:knife rarity:covert tradable:no price>5000 doppler
+func ParseItemFilter(query string) (ItemFilter, error) {
+	var filter ItemFilter
+
+	tokens := splitQuery(query)
+	if len(tokens) == 0 {
+		return filter, errors.New("пустой запрос")
+	}
+
+	for _, token := range tokens {
+		lower := strings.ToLower(token)
+
+		if match := pricePattern.FindStringSubmatch(lower); match != nil {
+			value, _ := strconv.ParseFloat(strings.Replace(match[2], ",", ".", 1), 64)
+			// Цены в рублях с копейками: строгие сравнения сдвигаем на копейку
+			minPrice, maxPrice := value+0.01, value-0.01
+			switch match[1] {
+			case ">":
+				filter.MinPrice = &minPrice
+			case ">=":
+				filter.MinPrice = &value
+			case "<":
+				filter.MaxPrice = &maxPrice
+			case "<=":
+				filter.MaxPrice = &value
+			case "=":
+				filter.MinPrice, filter.MaxPrice = &value, &value
+			}
+			continue
+		}
+
+		key, value, found := strings.Cut(lower, ":")
+		if !found {
+			filter.Names = append(filter.Names, lower)
+			continue
+		}
+		if value == "" {
+			return filter, fmt.Errorf("не указано значение для %s:", key)
+		}
+
+		switch key {
+		case "type":
+			filter.Type = value
+		case "rarity":
+			filter.Rarity = value
+		case "exterior":
+			filter.Exterior = value
+		case "tradable":
+			switch value {
+			case "yes", "да":
+				tradable := true
+				filter.Tradable = &tradable
+			case "no", "нет":
+				tradable := false
+				filter.Tradable = &tradable
+			default:
+				return filter, fmt.Errorf("tradable может быть yes или no, а не %s", value)
+			}
+		default:
+			return filter, fmt.Errorf("неизвестный фильтр %s:", key)
+		}
+	}
+
+	if filter.MaxPrice != nil && *filter.MaxPrice < 0 {
+		return filter, errors.New("цена не бывает меньше нуля")
+	}
+	if filter.MinPrice != nil && filter.MaxPrice != nil && *filter.MinPrice > *filter.MaxPrice {
+		return filter, errors.New("минимальная цена больше максимальной")
+	}
+
+	return filter, nil
+}
+
+// Делим запрос на слова; фраза в кавычках - одно слово (type:"sniper rifle")
+func splitQuery(query string) []string {
+	var tokens []string
+	var current strings.Builder
+	quoted := false
+
+	for _, r := range query {
+		switch {
+		case r == '"' || r == '«' || r == '»':
+			quoted = !quoted
+		case r == ' ' && !quoted:
+			if current.Len() > 0 {
+				tokens = append(tokens, current.String())
+				current.Reset()
+			}
+		default:
+			current.WriteRune(r)
+		}
+	}
+	if current.Len() > 0 {
+		tokens = append(tokens, current.String())
+	}
+
+	return tokens
+}
+
+// Подходит ли предмет под фильтр
+func (f ItemFilter) Match(item InventoryItem) bool {
+	name := strings.ToLower(item.Name + " " + item.MarketHashName)
+	for _, part := range f.Names {
+		if !strings.Contains(name, part) {
+			return false
+		}
+	}
+
+	// Тип ищем и в строке типа ("★ Covert Knife"), и в тегах Type/Weapon
+	if f.Type != "" && !containsFold(f.Type, item.Type, item.Tag("Type"), item.Tag("Weapon")) {
+		return false
+	}
+	if f.Rarity != "" && !containsFold(f.Rarity, item.Tag("Rarity")) {
+		return false
+	}
+	if f.Exterior != "" && !containsFold(f.Exterior, item.Tag("Exterior")) {
+		return false
+	}
+	if f.Tradable != nil && item.Tradable != *f.Tradable {
+		return false
+	}
+	if f.MinPrice != nil && item.PriceValue < *f.MinPrice {
+		return false
+	}
+	if f.MaxPrice != nil && item.PriceValue > *f.MaxPrice {
+		return false
+	}
+
+	return true
+}
+
+// Содержит ли хотя бы одно из значений подстроку needle (без учета регистра)
+func containsFold(needle string, values ...string) bool {
+	for _, value := range values {
+		if strings.Contains(strings.ToLower(value), needle) {
+			return true
+		}
+	}
+	return false
+}
+
+func (tb *TelegramBot) handleFindCommand(chatID int64, text string) {
+	query := strings.TrimSpace(strings.TrimPrefix(text, "/find"))
+	if query == "" {
+		tb.sendMessage(chatID, "Использование: /find <запрос>\nПример: /find type:knife price>5000")
+		return
+	}
+
+	filter, err := ParseItemFilter(query)
+	if err != nil {
+		tb.sendMessage(chatID, "❌ "+escapeMarkdown(err.Error())+"\nПример: /find type:knife price>5000")
+		return
+	}
+
+	result, ok := tb.lastScan(chatID)
+	if !ok {
+		return
+	}
+
+	var matched []InventoryItem
+	for _, item := range result.Items {
+		if filter.Match(item) {
+			matched = append(matched, item)
+		}
+	}
+
+	tb.sendMessage(chatID, formatFindResults(result, matched))
+}
+
+// Текст ответа на /find: найденные предметы по убыванию цены
+func formatFindResults(result *ScanResult, matched []InventoryItem) string {
+	if len(matched) == 0 {
+		return "🔎 Ничего не найдено"
+	}
+
+	count, total := 0, 0.0
+	for _, item := range matched {
+		count += item.Quantity()
+		total += item.Value()
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "🔎 *Найдено %d предметов* в инвентаре %s на %.2f ₽\n\n", count, result.SteamID, total)
+
+	rows := groupItems(matched, sortByPrice)
+	for i, row := range rows {
+		if i == findResultsLimit {
+			fmt.Fprintf(&b, "\n…и еще %d. Уточните запрос или откройте «📋 Все предметы».", len(rows)-findResultsLimit)
+			break
+		}
+
+		fmt.Fprintf(&b, "%d. %s\n", i+1, escapeMarkdown(row.Item.Name))
+		if row.Quantity > 1 {
+			fmt.Fprintf(&b, "   💰 %.2f ₽ × %d = %.2f ₽", row.Item.PriceValue, row.Quantity, row.Total)
+		} else {
+			fmt.Fprintf(&b, "   💰 %.2f ₽", row.Item.PriceValue)
+		}
+		if !row.Item.Tradable {
+			b.WriteString(" 🔒")
+		}
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"log"
+	"math"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Сколько последних снимков показываем в таблице /history
+const historyRows = 20
+
+func (tb *TelegramBot) handleHistoryCommand(chatID int64, text string) {
+	parts := strings.Fields(text)
+	if len(parts) < 2 {
+		tb.sendMessage(chatID, "Использование: /history <steam\\_id> [app\\_id]")
+		return
+	}
+
+	steamID, ok := tb.resolveInput(chatID, parts[1])
+	if !ok {
+		return
+	}
+
+	appID := "730" // CS:GO по умолчанию
+	if len(parts) > 2 {
+		appID = parts[2]
+	}
+
+	snapshots := tb.snapshots.List(steamID, appID)
+	if len(snapshots) == 0 {
+		tb.sendMessage(chatID, "📭 Снимков этого инвентаря еще нет. Они сохраняются после каждого сканирования: /scan "+steamID+" "+appID)
+		return
+	}
+
+	msg := tgbotapi.NewMessage(chatID, formatHistory(steamID, appID, snapshots))
+	msg.ParseMode = "Markdown"
+	if len(snapshots) > 1 {
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("📈 График", fmt.Sprintf("historychart_%s_%s", steamID, appID)),
+			),
+		)
+	}
+
+	if _, err := tb.bot.Send(msg); err != nil {
+		log.Printf("Ошибка отправки сообщения: %v", err)
+	}
+}
+
+// Таблица изменения стоимости по снимкам
+func formatHistory(steamID, appID string, snapshots []*Snapshot) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "🕰 *История инвентаря %s* (%s)\n\n", steamID, getGameName(appID))
+
+	shown := snapshots
+	if len(shown) > historyRows {
+		shown = shown[len(shown)-historyRows:]
+	}
+
+	b.WriteString("```\n")
+	fmt.Fprintf(&b, "%-11s %14s %8s\n", "Дата", "Стоимость", "Изм.")
+	for i, snapshot := range shown {
+		change := ""
+		var previous *Snapshot
+		if i > 0 {
+			previous = shown[i-1]
+		} else if len(shown) < len(snapshots) {
+			previous = snapshots[len(snapshots)-len(shown)-1]
+		}
+		if previous != nil {
+			change = formatChange(previous.TotalValue, snapshot.TotalValue)
+		}
+
+		mark := ""
+		if snapshot.Partial {
+			mark = "*"
+		}
+		fmt.Fprintf(&b, "%-11s %14.2f %8s%s\n", snapshot.TakenAt.Format("02.01 15:04"), snapshot.TotalValue, change, mark)
+	}
+	b.WriteString("```\n")
+
+	first, last := snapshots[0], snapshots[len(snapshots)-1]
+	fmt.Fprintf(&b, "\nСнимков: %d с %s\n", len(snapshots), first.TakenAt.Format("02.01.2006"))
+	fmt.Fprintf(&b, "💵 Изменение за период: %+.2f ₽ (%s)", last.TotalValue-first.TotalValue, formatChange(first.TotalValue, last.TotalValue))
+
+	for _, snapshot := range shown {
+		if snapshot.Partial {
+			b.WriteString("\n\\* неполные данные сканирования")
+			break
+		}
+	}
+
+	return b.String()
+}
+
+// Изменение в процентах: "+2.5%"
+func formatChange(from, to float64) string {
+	if from == 0 {
+		return "—"
+	}
+	return fmt.Sprintf("%+.1f%%", (to-from)/from*100)
+}
+
+// Линейный график стоимости по снимкам
+func RenderHistoryChart(steamID string, snapshots []*Snapshot) ([]byte, error) {
+	if len(snapshots) < 2 {
+		return nil, errors.New("нужно хотя бы два снимка")
+	}
+
+	canvas := image.NewRGBA(image.Rect(0, 0, chartWidth, chartHeight))
+	fillRect(canvas, canvas.Bounds(), colorBackground)
+
+	titleFace := newFace(true, 26)
+	labelFace := newFace(false, 15)
+
+	drawText(canvas, titleFace, 24, 44, "Стоимость инвентаря "+steamID, colorText)
+
+	minValue, maxValue := math.Inf(1), math.Inf(-1)
+	for _, snapshot := range snapshots {
+		minValue = math.Min(minValue, snapshot.TotalValue)
+		maxValue = math.Max(maxValue, snapshot.TotalValue)
+	}
+	if maxValue == minValue {
+		maxValue = minValue + 1
+	}
+
+	left, right, top, bottom := 150, chartWidth-40, 90, chartHeight-60
+	fillRect(canvas, image.Rect(left, bottom, right, bottom+2), colorMuted)
+	fillRect(canvas, image.Rect(left-2, top, left, bottom), colorMuted)
+
+	drawText(canvas, labelFace, 16, top+6, formatRubles(maxValue), colorMuted)
+	drawText(canvas, labelFace, 16, bottom, formatRubles(minValue), colorMuted)
+
+	start, end := snapshots[0].TakenAt, snapshots[len(snapshots)-1].TakenAt
+	span := end.Sub(start).Seconds()
+	if span == 0 {
+		span = 1
+	}
+
+	drawText(canvas, labelFace, left, bottom+26, start.Format("02.01.2006"), colorMuted)
+	endLabel := end.Format("02.01.2006")
+	drawText(canvas, labelFace, right-textWidth(labelFace, endLabel), bottom+26, endLabel, colorMuted)
+
+	point := func(snapshot *Snapshot) (float64, float64) {
+		x := float64(left) + snapshot.TakenAt.Sub(start).Seconds()/span*float64(right-left)
+		y := float64(bottom) - (snapshot.TotalValue-minValue)/(maxValue-minValue)*float64(bottom-top)
+		return x, y
+	}
+
+	// Линия из точек толщиной 3 пикселя с шагом в полпикселя
+	for i := 1; i < len(snapshots); i++ {
+		x0, y0 := point(snapshots[i-1])
+		x1, y1 := point(snapshots[i])
+		steps := int(math.Max(math.Abs(x1-x0), math.Abs(y1-y0))*2) + 1
+		for step := 0; step <= steps; step++ {
+			t := float64(step) / float64(steps)
+			x, y := int(x0+(x1-x0)*t), int(y0+(y1-y0)*t)
+			fillRect(canvas, image.Rect(x-1, y-1, x+2, y+2), colorAccent)
+		}
+	}
+
+	for _, snapshot := range snapshots {
+		x, y := point(snapshot)
+		fillRect(canvas, image.Rect(int(x)-3, int(y)-3, int(x)+4, int(y)+4), colorPrice)
+	}
+
+	return encodePNG(canvas)
+}
+
+func (tb *TelegramBot) sendHistoryChart(chatID int64, steamID, appID string) {
+	data, err := RenderHistoryChart(steamID, tb.snapshots.List(steamID, appID))
+	if err != nil {
+		tb.sendMessage(chatID, "❌ Для графика нужно хотя бы два снимка")
+		return
+	}
+
+	photo := tgbotapi.NewPhoto(chatID, tgbotapi.FileBytes{Name: "history.png", Bytes: data})
+	photo.Caption = fmt.Sprintf("📈 История стоимости %s (%s)", steamID, getGameName(appID))
+
+	if _, err := tb.bot.Send(photo); err != nil {
+		log.Printf("Ошибка отправки фото: %v", err)
+	}
+}
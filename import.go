@@ -0,0 +1,163 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Максимальный размер загружаемого файла (Telegram отдает ботам файлы до 20 МБ)
+const maxImportSize = 20 << 20
+
+// Префикс идентификатора загруженного инвентаря вместо Steam ID
+const importIDPrefix = "file"
+
+// Загруженный инвентарь: отчеты по нему строятся так же, как по профилю
+type InventoryImport struct {
+	ID        string // importIDPrefix + начало хэша содержимого
+	AppID     string
+	Inventory *SteamInventoryResponse
+}
+
+// Разбираем ответ /inventory/ Steam, сохраненный в файл
+func ParseInventoryImport(data []byte) (*InventoryImport, error) {
+	var inventory SteamInventoryResponse
+	if err := json.Unmarshal(data, &inventory); err != nil {
+		return nil, errors.New("файл не похож на JSON инвентаря Steam")
+	}
+
+	if inventory.Error != "" {
+		return nil, fmt.Errorf("в файле сохранена ошибка Steam: %s", inventory.Error)
+	}
+	if len(inventory.Assets) == 0 || len(inventory.Descriptions) == 0 {
+		return nil, errors.New("в файле нет предметов (ожидаются поля assets и descriptions)")
+	}
+
+	appID := inventory.Assets[0].AppID
+	for _, asset := range inventory.Assets {
+		if asset.AppID != appID {
+			return nil, errors.New("в файле предметы из разных игр")
+		}
+	}
+
+	if inventory.TotalCount < len(inventory.Assets) {
+		inventory.TotalCount = len(inventory.Assets)
+	}
+
+	// Одинаковые файлы получают одинаковый идентификатор и разделяют кэш
+	sum := sha256.Sum256(data)
+
+	return &InventoryImport{
+		ID:        importIDPrefix + hex.EncodeToString(sum[:6]),
+		AppID:     strconv.Itoa(appID),
+		Inventory: &inventory,
+	}, nil
+}
+
+// Относится ли идентификатор к загруженному файлу, а не к профилю Steam
+func isImportID(steamID string) bool {
+	return strings.HasPrefix(steamID, importIDPrefix)
+}
+
+// Пользователь прислал файл: скачиваем, разбираем и ставим в очередь на оценку.
+// Скачивание идет в отдельной горутине, чтобы не задерживать обработку обновлений
+func (tb *TelegramBot) handleDocument(chatID, userID int64, document *tgbotapi.Document) {
+	if !strings.HasSuffix(strings.ToLower(document.FileName), ".json") && document.MimeType != "application/json" {
+		tb.sendMessage(chatID, "📄 Пришлите JSON-файл инвентаря Steam (ответ steamcommunity.com/inventory/...)")
+		return
+	}
+
+	if document.FileSize > maxImportSize {
+		tb.sendMessage(chatID, "❌ Файл слишком большой. Максимум 20 МБ.")
+		return
+	}
+
+	go tb.importDocument(chatID, userID, document.FileID)
+}
+
+func (tb *TelegramBot) importDocument(chatID, userID int64, fileID string) {
+	data, err := tb.downloadFile(fileID)
+	if err != nil {
+		log.Printf("Ошибка загрузки файла: %v", err)
+		tb.sendMessage(chatID, "❌ Не удалось скачать файл. Попробуйте еще раз.")
+		return
+	}
+
+	imported, err := ParseInventoryImport(data)
+	if err != nil {
+		tb.sendMessage(chatID, "❌ "+escapeMarkdown(err.Error()))
+		return
+	}
+
+	// Тот же файл недавно оценивали: отдаем результат без запросов цен
+	key := scanKey(imported.ID, imported.AppID, defaultContextID, marketCurrency)
+	if entry, exists := tb.cache.GetEntry(key); exists && !entry.Stale() {
+		tb.sendMessage(chatID, "⚡ Этот файл уже оценивался, использую кэшированные данные...")
+		tb.sendScanResult(chatID, entry.Value, "из кэша")
+		return
+	}
+
+	tb.sendMessage(chatID, fmt.Sprintf("📥 Файл принят: %s, %d предметов. Ставлю в очередь на оценку.",
+		getGameName(imported.AppID), len(imported.Inventory.Assets)))
+
+	tb.enqueueImport(chatID, userID, imported)
+}
+
+// Скачиваем файл, присланный боту
+func (tb *TelegramBot) downloadFile(fileID string) ([]byte, error) {
+	fileURL, err := tb.bot.GetFileDirectURL(fileID)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &http.Client{Timeout: time.Minute}
+	resp, err := client.Get(fileURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("file status: %s", resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxImportSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxImportSize {
+		return nil, errors.New("file too large")
+	}
+	return data, nil
+}
+
+// Оцениваем загруженный инвентарь тем же кодом, что и живое сканирование
+func (tb *TelegramBot) scanImported(job *ScanJob) {
+	if !tb.steam.Available(EndpointMarket) {
+		tb.finishJob(job, "🔌 Торговая площадка Steam сейчас недоступна. Попробуйте позже.")
+		return
+	}
+
+	inventory := job.Import
+	result := &ScanResult{
+		SteamID:         job.SteamID,
+		AppID:           job.AppID,
+		TotalCount:      inventory.TotalCount,
+		FetchedCount:    len(inventory.Assets),
+		MarketableCount: countMarketable(inventory.Assets, inventory.Descriptions),
+		FetchPartial:    len(inventory.Assets) < inventory.TotalCount,
+		ScannedAt:       time.Now(),
+	}
+
+	tb.priceInventory(job, result, inventory.Assets, inventory.Descriptions)
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Адрес картинок предметов в CDN Steam
+const steamImageURL = "https://community.cloudflare.steamstatic.com/economy/image/"
+
+// Ссылка осмотра предмета в игре из действий описания
+func inspectLink(desc Description) string {
+	for _, action := range desc.Actions {
+		if strings.Contains(action.Link, "%assetid%") {
+			return action.Link
+		}
+	}
+	return ""
+}
+
+// Ссылка на страницу предмета на торговой площадке
+func marketListingURL(appID, marketHashName string) string {
+	return fmt.Sprintf("https://steamcommunity.com/market/listings/%s/%s", appID, url.PathEscape(marketHashName))
+}
+
+// Кнопки карточек для списка предметов; номера кнопок начинаются с first
+func itemButtons(steamID, appID string, items []InventoryItem, first int) [][]tgbotapi.InlineKeyboardButton {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	var row []tgbotapi.InlineKeyboardButton
+
+	for i, item := range items {
+		data := fmt.Sprintf("item_%s_%s_%s", steamID, appID, item.AssetID)
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("%d", first+i), data))
+		if len(row) == 5 {
+			rows = append(rows, row)
+			row = nil
+		}
+	}
+	if len(row) > 0 {
+		rows = append(rows, row)
+	}
+
+	return rows
+}
+
+// Ищем предмет результата по asset ID
+func findItem(result *ScanResult, assetID string) (InventoryItem, bool) {
+	for _, item := range result.Items {
+		if item.AssetID == assetID {
+			return item, true
+		}
+	}
+	return InventoryItem{}, false
+}
+
+// Показываем карточку предмета: картинка, теги, цены и ссылки
+func (tb *TelegramBot) sendItemCard(chatID, userID int64, steamID, appID, assetID string) {
+	result, ok := tb.scanResult(chatID, steamID, appID)
+	if !ok {
+		return
+	}
+
+	item, found := findItem(result, assetID)
+	if !found {
+		tb.sendMessage(chatID, "❌ Предмет не найден в последнем сканировании")
+		return
+	}
+
+	// Медиана и объем обычно уже в общем кэше цен после сканирования;
+	// если нет, запрос к торговой площадке расходует квоту /price
+	overview, cached := tb.steam.prices.Get(appID + "_" + item.MarketHashName)
+	if !cached {
+		if allowed, _ := tb.priceQuota.Allow(userID); allowed {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			var err error
+			overview, err = tb.steam.getMarketOverview(ctx, appID, item.MarketHashName, false)
+			cancel()
+			if err != nil && !errors.Is(err, ErrCircuitOpen) {
+				log.Printf("Ошибка получения цены для карточки: %v", err)
+			}
+		}
+	}
+
+	caption := formatItemCard(item, overview)
+	keyboard := itemCardKeyboard(result, item)
+
+	icon := item.IconURLLarge
+	if icon == "" {
+		icon = item.IconURL
+	}
+
+	if icon == "" {
+		msg := tgbotapi.NewMessage(chatID, caption)
+		msg.ParseMode = "Markdown"
+		if len(keyboard.InlineKeyboard) > 0 {
+			msg.ReplyMarkup = keyboard
+		}
+		if _, err := tb.bot.Send(msg); err != nil {
+			log.Printf("Ошибка отправки сообщения: %v", err)
+		}
+		return
+	}
+
+	photo := tgbotapi.NewPhoto(chatID, tgbotapi.FileURL(steamImageURL+icon))
+	photo.Caption = caption
+	photo.ParseMode = "Markdown"
+	if len(keyboard.InlineKeyboard) > 0 {
+		photo.ReplyMarkup = keyboard
+	}
+
+	if _, err := tb.bot.Send(photo); err != nil {
+		log.Printf("Ошибка отправки фото: %v", err)
+	}
+}
+
+// Текст карточки предмета (подпись к фото, не длиннее 1024 символов)
+func formatItemCard(item InventoryItem, overview MarketPrice) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%s*\n", escapeMarkdown(item.Name))
+	if item.Type != "" {
+		fmt.Fprintf(&b, "_%s_\n", escapeMarkdown(item.Type))
+	}
+
+	if len(item.Tags) > 0 {
+		b.WriteString("\n")
+		for _, tag := range item.Tags {
+			if tag.LocalizedCategoryName == "" || tag.LocalizedTagName == "" {
+				continue
+			}
+			fmt.Fprintf(&b, "🏷 %s: %s\n", escapeMarkdown(tag.LocalizedCategoryName), escapeMarkdown(tag.LocalizedTagName))
+		}
+	}
+
+	b.WriteString("\n")
+	if overview.Lowest != "" || overview.Median != "" {
+		fmt.Fprintf(&b, "💰 Минимальная цена: %s\n", valueOrDash(overview.Lowest))
+		fmt.Fprintf(&b, "📊 Медианная цена: %s\n", valueOrDash(overview.Median))
+		fmt.Fprintf(&b, "📦 Продано за сутки: %s\n", valueOrDash(overview.Volume))
+	} else {
+		fmt.Fprintf(&b, "💰 Цена при сканировании: %.2f ₽\n", item.PriceValue)
+	}
+
+	if item.Quantity() > 1 {
+		fmt.Fprintf(&b, "🔢 Количество: %d\n", item.Quantity())
+	}
+
+	if item.Tradable {
+		b.WriteString("🔁 Обмен: доступен")
+	} else {
+		b.WriteString("🔒 Обмен: недоступен")
+	}
+
+	text := b.String()
+	if len([]rune(text)) > 1024 {
+		text = string([]rune(text)[:1020]) + "…"
+	}
+	return text
+}
+
+func valueOrDash(value string) string {
+	if value == "" {
+		return "—"
+	}
+	return value
+}
+
+// Кнопки карточки: страница на торговой площадке и ссылка осмотра
+func itemCardKeyboard(result *ScanResult, item InventoryItem) tgbotapi.InlineKeyboardMarkup {
+	var row []tgbotapi.InlineKeyboardButton
+	if item.MarketHashName != "" {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonURL("🛒 Торговая площадка", marketListingURL(result.AppID, item.MarketHashName)))
+	}
+	// Telegram не открывает steam:// в кнопках, поэтому ссылку осмотра присылаем текстом
+	if item.InspectLink != "" && !isImportID(result.SteamID) {
+		data := fmt.Sprintf("inspect_%s_%s_%s", result.SteamID, result.AppID, item.AssetID)
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData("🔍 Осмотреть в игре", data))
+	}
+
+	if len(row) == 0 {
+		return tgbotapi.InlineKeyboardMarkup{}
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(row)
+}
+
+// Присылаем ссылку осмотра предмета в игре
+func (tb *TelegramBot) sendInspectLink(chatID int64, steamID, appID, assetID string) {
+	result, ok := tb.scanResult(chatID, steamID, appID)
+	if !ok {
+		return
+	}
+
+	item, found := findItem(result, assetID)
+	if !found || item.InspectLink == "" {
+		tb.sendMessage(chatID, "❌ Для этого предмета нет ссылки осмотра")
+		return
+	}
+
+	link := strings.NewReplacer("%owner_steamid%", result.SteamID, "%assetid%", item.AssetID).Replace(item.InspectLink)
+	tb.sendMessage(chatID, "🔍 Ссылка для осмотра в игре (скопируйте и откройте в браузере или Steam):\n`"+link+"`")
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Вид задач планировщика для живых сообщений
+const liveKind = "live"
+
+// Как часто обновляем живое сообщение
+const liveInterval = 10 * time.Minute
+
+// Данные задачи живого сообщения (одно на чат)
+type LivePayload struct {
+	MessageID int  `json:"message_id"`
+	Pinned    bool `json:"pinned"`
+}
+
+func liveID(chatID int64) string {
+	return fmt.Sprintf("live_%d", chatID)
+}
+
+// Ошибки Telegram, после которых сообщение больше не обновить
+var liveGoneErrors = []string{
+	"message to edit not found",
+	"message can't be edited",
+	"chat not found",
+	"bot was kicked",
+	"bot was blocked",
+	"not enough rights",
+}
+
+// Сообщение не изменилось - это не ошибка
+func isNotModified(err error) bool {
+	var apiErr *tgbotapi.Error
+	return errors.As(err, &apiErr) && strings.Contains(apiErr.Message, "message is not modified")
+}
+
+// Сообщение удалено или бот потерял доступ к чату
+func isMessageGone(err error) bool {
+	var apiErr *tgbotapi.Error
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	for _, text := range liveGoneErrors {
+		if strings.Contains(apiErr.Message, text) {
+			return true
+		}
+	}
+	return false
+}
+
+func (tb *TelegramBot) handleLiveCommand(chatID, userID int64, text string) {
+	parts := strings.Fields(text)
+	id := liveID(chatID)
+
+	if len(parts) > 1 && parts[1] == "off" {
+		task, exists := tb.scheduler.Get(id)
+		if !exists {
+			tb.sendMessage(chatID, "Живого сообщения в этом чате нет")
+			return
+		}
+		tb.scheduler.Remove(id)
+		if payload, ok := taskPayload[LivePayload](task); ok {
+			tb.unpinLive(chatID, payload)
+		}
+		tb.sendMessage(chatID, "✅ Живое сообщение больше не обновляется")
+		return
+	}
+
+	// Новое сообщение заменяет прежнее
+	if task, exists := tb.scheduler.Get(id); exists {
+		if payload, ok := taskPayload[LivePayload](task); ok {
+			tb.unpinLive(chatID, payload)
+		}
+	}
+
+	msg := tgbotapi.NewMessage(chatID, tb.buildLive(chatID, userID))
+	msg.ParseMode = "Markdown"
+	sent, err := tb.bot.Send(msg)
+	if err != nil {
+		log.Printf("Ошибка отправки сообщения: %v", err)
+		return
+	}
+
+	payload := LivePayload{MessageID: sent.MessageID}
+	pin := tgbotapi.PinChatMessageConfig{ChatID: chatID, MessageID: sent.MessageID, DisableNotification: true}
+	if _, err := tb.bot.Request(pin); err != nil {
+		log.Printf("Ошибка закрепления сообщения: %v", err)
+		tb.sendMessage(chatID, "📌 Не удалось закрепить сообщение. Дайте боту право закреплять сообщения или закрепите его вручную - обновляться оно будет в любом случае.")
+	} else {
+		payload.Pinned = true
+	}
+
+	task := newTask(id, liveKind, chatID, userID, payload)
+	task.Interval = liveInterval
+	task.NextRun = time.Now().Add(liveInterval)
+	tb.scheduler.Add(task)
+}
+
+// Открепляем прежнее живое сообщение
+func (tb *TelegramBot) unpinLive(chatID int64, payload LivePayload) {
+	if !payload.Pinned {
+		return
+	}
+	unpin := tgbotapi.UnpinChatMessageConfig{ChatID: chatID, MessageID: payload.MessageID}
+	if _, err := tb.bot.Request(unpin); err != nil && !isMessageGone(err) {
+		log.Printf("Ошибка открепления сообщения: %v", err)
+	}
+}
+
+// Плановое обновление живого сообщения. Само оно Steam не запрашивает: профили
+// пересканируются по расписанию наблюдений, сообщение показывает их последние снимки
+func (tb *TelegramBot) runLive(task ScheduledTask) time.Time {
+	payload, ok := taskPayload[LivePayload](task)
+	if !ok {
+		return time.Time{}
+	}
+
+	edit := tgbotapi.NewEditMessageText(task.ChatID, payload.MessageID, tb.buildLive(task.ChatID, task.UserID))
+	edit.ParseMode = "Markdown"
+
+	_, err := tb.bot.Send(edit)
+	switch {
+	case err == nil, isNotModified(err):
+	case isMessageGone(err):
+		// Сообщение удалили или бота убрали из чата: больше не обновляем
+		log.Printf("Живое сообщение в чате %d больше недоступно: %v", task.ChatID, err)
+		return time.Time{}
+	default:
+		log.Printf("Ошибка обновления живого сообщения: %v", err)
+	}
+
+	return time.Now().Add(task.Interval)
+}
+
+// Текст живого сообщения: стоимость наблюдаемых профилей по последним снимкам.
+// Время показываем в поясе сводки пользователя, создавшего сообщение
+func (tb *TelegramBot) buildLive(chatID, userID int64) string {
+	location := tb.userLocation(userID)
+
+	var b strings.Builder
+	b.WriteString("📌 *Портфель чата*\n\n")
+
+	watches := tb.scheduler.List(watchKind, func(task ScheduledTask) bool {
+		return task.ChatID == chatID
+	})
+	if len(watches) == 0 {
+		b.WriteString("Нет наблюдаемых профилей. Добавьте: /watch <steam\\_id>\n")
+	}
+
+	now := time.Now()
+	total := 0.0
+	for _, watch := range watches {
+		watched, ok := taskPayload[WatchPayload](watch)
+		if !ok {
+			continue
+		}
+
+		snapshots := tb.snapshots.List(watched.SteamID, watched.AppID)
+		if len(snapshots) == 0 {
+			fmt.Fprintf(&b, "• %s (%s): ожидает сканирования\n", watched.SteamID, getGameName(watched.AppID))
+			continue
+		}
+
+		latest := snapshots[len(snapshots)-1]
+		total += latest.TotalValue
+
+		change := ""
+		if dayAgo, found := snapshotBefore(snapshots, now.Add(-24*time.Hour)); found {
+			change = ", за сутки " + formatChange(dayAgo.TotalValue, latest.TotalValue)
+		}
+		fmt.Fprintf(&b, "• %s (%s): %.2f ₽%s, снимок %s\n", watched.SteamID, getGameName(watched.AppID),
+			latest.TotalValue, change, formatAge(now.Sub(latest.TakenAt)))
+	}
+
+	if len(watches) > 0 {
+		fmt.Fprintf(&b, "\n💵 *Итого: %.2f ₽*\n", total)
+		b.WriteString("Стоимость - по последнему сканированию профиля, профили сканируются по расписанию /watch\n")
+	}
+	fmt.Fprintf(&b, "\n🕐 Обновлено %s", now.In(location).Format("02.01.2006 15:04 MST"))
+
+	return b.String()
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Сколько последних запросов учитываем в статистике эндпоинта
+const statsWindow = 100
+
+type requestSample struct {
+	latency time.Duration
+	failed  bool
+}
+
+// Статистика последних запросов к эндпоинту (кольцевой буфер)
+type EndpointStats struct {
+	mutex   sync.Mutex
+	samples []requestSample
+	next    int
+}
+
+func NewEndpointStats() *EndpointStats {
+	return &EndpointStats{
+		samples: make([]requestSample, 0, statsWindow),
+	}
+}
+
+// Записываем результат запроса
+func (s *EndpointStats) Record(latency time.Duration, failed bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	sample := requestSample{latency: latency, failed: failed}
+	if len(s.samples) < statsWindow {
+		s.samples = append(s.samples, sample)
+		return
+	}
+
+	s.samples[s.next] = sample
+	s.next = (s.next + 1) % statsWindow
+}
+
+// Сводка по последним запросам
+type StatsSummary struct {
+	Requests   int
+	AvgLatency time.Duration
+	P95Latency time.Duration
+	ErrorRate  float64
+}
+
+func (s *EndpointStats) Summary() StatsSummary {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	summary := StatsSummary{Requests: len(s.samples)}
+	if len(s.samples) == 0 {
+		return summary
+	}
+
+	latencies := make([]time.Duration, 0, len(s.samples))
+	var total time.Duration
+	failed := 0
+	for _, sample := range s.samples {
+		latencies = append(latencies, sample.latency)
+		total += sample.latency
+		if sample.failed {
+			failed++
+		}
+	}
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+	summary.AvgLatency = total / time.Duration(len(latencies))
+	summary.P95Latency = latencies[(len(latencies)*95-1)/100]
+	summary.ErrorRate = float64(failed) / float64(len(s.samples))
+
+	return summary
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Игры портфеля по умолчанию (переопределяются PORTFOLIO_GAMES=730,570)
+var defaultPortfolioGames = []string{"730", "570", "440", "252490"}
+
+// Игры, по которым собирается портфель
+func portfolioGames() []string {
+	var games []string
+	for _, appID := range strings.Split(os.Getenv("PORTFOLIO_GAMES"), ",") {
+		if appID = strings.TrimSpace(appID); appID != "" {
+			games = append(games, appID)
+		}
+	}
+	if len(games) == 0 {
+		return defaultPortfolioGames
+	}
+	return games
+}
+
+// Причины, по которым в игре нечего оценивать: это не ошибка, в отчете такие игры не показываем
+const (
+	problemEmpty        = "пусто или скрыт"
+	problemNoMarketable = "нет продаваемых предметов"
+)
+
+// Инвентарь одного аккаунта в одной игре
+type portfolioEntry struct {
+	Account LinkedAccount
+	AppID   string
+	Result  *ScanResult
+	Source  string // "из кэша", "устарело" или пусто для свежего сканирования
+	Problem string // почему инвентаря нет
+}
+
+// Портфель пользователя: все аккаунты во всех играх
+type Portfolio struct {
+	Accounts []LinkedAccount
+	Entries  []portfolioEntry
+
+	PriceLookups int // уникальных предметов, цены которых понадобились
+	PricedItems  int // позиций, для которых нужна была цена
+}
+
+func (tb *TelegramBot) handlePortfolioCommand(chatID, userID int64) {
+	accounts := tb.accounts.List(userID)
+	if len(accounts) == 0 {
+		tb.sendMessage(chatID, "🔗 Сначала привяжите аккаунты: /link <steam\\_id> [подпись]")
+		return
+	}
+
+	tb.portfoliosMutex.Lock()
+	running := tb.portfolios[userID]
+	tb.portfolios[userID] = true
+	tb.portfoliosMutex.Unlock()
+
+	if running {
+		tb.sendMessage(chatID, "⏳ Портфель уже собирается, результат придет сюда.")
+		return
+	}
+
+	// Сканирование нескольких аккаунтов долгое: не задерживаем другие обновления
+	go func() {
+		defer func() {
+			tb.portfoliosMutex.Lock()
+			delete(tb.portfolios, userID)
+			tb.portfoliosMutex.Unlock()
+		}()
+
+		portfolio := tb.collectPortfolio(chatID, userID, accounts)
+		tb.sendMessage(chatID, portfolio.Format())
+	}()
+}
+
+// Собираем инвентари всех аккаунтов. Свежие результаты берутся из кэша, остальные
+// сканируются через общую очередь по одному; цены одной игры запрашиваются один раз
+// на все аккаунты
+func (tb *TelegramBot) collectPortfolio(chatID, userID int64, accounts []LinkedAccount) *Portfolio {
+	games := portfolioGames()
+	portfolio := &Portfolio{Accounts: accounts}
+	total := len(accounts) * len(games)
+
+	statusID := 0
+	status := fmt.Sprintf("💼 Собираю портфель: %d аккаунтов × %d игр...", len(accounts), len(games))
+	if sent, err := tb.bot.Send(tgbotapi.NewMessage(chatID, status)); err == nil {
+		statusID = sent.MessageID
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Minute)
+	defer cancel()
+
+	quotaChecked, quotaAllowed, queued := false, false, false
+
+	for _, appID := range games {
+		prices := make(map[string]MarketPrice)
+
+		for _, account := range accounts {
+			entry := portfolioEntry{Account: account, AppID: appID}
+			key := scanKey(account.SteamID, appID, defaultContextID, marketCurrency)
+			cached, hasCache := tb.cache.GetEntry(key)
+
+			switch {
+			case hasCache && !cached.Stale():
+				entry.Result, entry.Source = cached.Value, "из кэша"
+			case ctx.Err() != nil:
+				entry.Problem = "таймаут"
+			case !tb.steam.Available(EndpointInventory):
+				entry.Problem = "Steam недоступен"
+			default:
+				// Одна квота сканирования на весь портфель
+				if !quotaChecked {
+					quotaChecked = true
+					quotaAllowed, _ = tb.scanQuota.Allow(userID)
+				}
+				if !quotaAllowed {
+					entry.Problem = "лимит сканирований"
+					break
+				}
+
+				job, err := tb.enqueuePortfolio(userID, account.SteamID, appID, prices)
+				if err != nil {
+					entry.Problem = "очередь занята"
+					break
+				}
+				queued = true
+
+				select {
+				case <-tb.jobDone(job):
+					entry.Result, entry.Problem = job.result, portfolioProblem(job)
+				case <-ctx.Done():
+					entry.Problem = "таймаут"
+				}
+			}
+
+			// Устаревшие данные лучше, чем никаких
+			if entry.Result == nil && !entry.Empty() && hasCache {
+				entry.Result, entry.Source, entry.Problem = cached.Value, "устарело", ""
+			}
+
+			portfolio.Entries = append(portfolio.Entries, entry)
+
+			if statusID != 0 {
+				tb.editMessage(chatID, statusID, fmt.Sprintf("💼 Собираю портфель: %d из %d", len(portfolio.Entries), total))
+			}
+		}
+
+		// После таймаута задание игры может еще работать со словарем цен
+		if ctx.Err() == nil {
+			portfolio.PriceLookups += len(prices)
+		}
+	}
+
+	// Очередь не приняла ни одного задания - квоту не расходуем
+	if quotaAllowed && !queued {
+		tb.scanQuota.Refund(userID)
+	}
+
+	for _, entry := range portfolio.Entries {
+		if entry.Result != nil && entry.Source == "" {
+			portfolio.PricedItems += len(entry.Result.Items)
+		}
+	}
+
+	return portfolio
+}
+
+// Почему задание портфеля не дало результата
+func portfolioProblem(job *ScanJob) string {
+	switch {
+	case job.result != nil:
+		return ""
+	case job.failure == emptyInventoryText:
+		return problemEmpty
+	case job.failure == noMarketableText:
+		return problemNoMarketable
+	case job.failure == inventoryUnavailableText:
+		return "Steam недоступен"
+	case job.failure == scanTimeoutText:
+		return "таймаут"
+	}
+	return "ошибка сканирования"
+}
+
+// В игре у аккаунта нечего оценивать
+func (e portfolioEntry) Empty() bool {
+	return e.Problem == problemEmpty || e.Problem == problemNoMarketable
+}
+
+// Стоимость инвентаря
+func (e portfolioEntry) Value() float64 {
+	if e.Result == nil {
+		return 0
+	}
+	total := 0.0
+	for _, item := range e.Result.Items {
+		total += item.Value()
+	}
+	return total
+}
+
+// Отчет по портфелю: итог, стоимость по аккаунтам и по играм
+func (p *Portfolio) Format() string {
+	var b strings.Builder
+	b.WriteString("💼 *Портфель*\n")
+
+	total := 0.0
+	byGame := make(map[string]float64)
+	partial := false
+
+	for _, account := range p.Accounts {
+		accountTotal := 0.0
+		var lines []string
+
+		for _, entry := range p.Entries {
+			if entry.Account.SteamID != account.SteamID {
+				continue
+			}
+
+			// Пустые инвентари игр, в которые аккаунт не играет, не показываем
+			if entry.Result == nil {
+				if !entry.Empty() {
+					lines = append(lines, fmt.Sprintf("  • %s: нет данных (%s)", getGameName(entry.AppID), entry.Problem))
+				}
+				continue
+			}
+
+			value := entry.Value()
+			accountTotal += value
+			byGame[entry.AppID] += value
+			partial = partial || entry.Result.Partial()
+
+			line := fmt.Sprintf("  • %s: %.2f ₽ (%d поз.)", getGameName(entry.AppID), value, len(entry.Result.Items))
+			if entry.Source != "" {
+				line += ", " + entry.Source
+			}
+			lines = append(lines, line)
+		}
+
+		total += accountTotal
+		fmt.Fprintf(&b, "\n*%s* (%s): %.2f ₽\n", escapeMarkdown(account.Label), account.SteamID, accountTotal)
+		if len(lines) == 0 {
+			b.WriteString("  • предметов на площадке нет\n")
+		}
+		for _, line := range lines {
+			b.WriteString(line + "\n")
+		}
+	}
+
+	fmt.Fprintf(&b, "\n💵 *Итого: %.2f ₽*\n", total)
+
+	var games []string
+	for _, appID := range portfolioGames() {
+		if value, exists := byGame[appID]; exists {
+			games = append(games, fmt.Sprintf("%s %.2f ₽", getGameName(appID), value))
+		}
+	}
+	if len(games) > 0 {
+		b.WriteString("🎮 По играм: " + strings.Join(games, ", ") + "\n")
+	}
+
+	if p.PricedItems > 0 {
+		fmt.Fprintf(&b, "💰 Цены: %d уникальных предметов на %d позиций всех аккаунтов\n", p.PriceLookups, p.PricedItems)
+	}
+	if partial {
+		fmt.Fprintf(&b, "⚠️ Часть инвентарей оценена не полностью (не больше %d предметов на инвентарь)\n", maxPricedItems)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	ErrQueueFull     = errors.New("очередь сканирований переполнена")
+	ErrUserQueueFull = errors.New("слишком много сканирований пользователя в очереди")
+)
+
+// Задание на сканирование инвентаря. Одновременные запросы одного и того же
+// профиля объединяются в одно задание с несколькими подписчиками
+type ScanJob struct {
+	UserID    int64 // пользователь, поставивший задание (для справедливой очереди)
+	SteamID   string
+	AppID     string
+	Key       string
+	Cached    bool // результат уже в кэше, задание обслуживается вне общей очереди
+	Refresh   bool // фоновое обновление устаревшего кэша, без подписчиков
+	Scheduled bool // плановое пересканирование наблюдаемого профиля, кэш не используется
+	Force     bool // принудительное обновление, кэш не используется
+	Portfolio bool // сканирование для портфеля, устаревший кэш не используется
+
+	// Общий словарь цен игры для всех аккаунтов портфеля (nil - свой у задания)
+	Prices map[string]MarketPrice
+
+	// Инвентарь из загруженного файла: Steam запрашивается только за ценами
+	Import *SteamInventoryResponse
+
+	subscribers      []*jobSubscriber
+	running          bool
+	throttleNotified bool
+
+	result   *ScanResult   // итог задания, если оно дало результат
+	failure  string        // иначе - сообщение об ошибке
+	finished bool          // задание выполнено
+	done     chan struct{} // закрывается по завершении, создается ожидающим
+}
+
+// Чат, ожидающий результат задания
+type jobSubscriber struct {
+	chatID          int64
+	statusMessageID int
+	lastPosition    int
+	sending         bool // первое сообщение о позиции еще отправляется
+	started         bool // задание пошло в работу
+}
+
+// Очередь сканирований: задания из кэша обслуживаются первыми,
+// остальные выдаются по кругу между пользователями, чтобы один большой
+// инвентарь не задерживал всех остальных
+type ScanQueue struct {
+	mutex    sync.Mutex
+	ready    chan struct{} // по одному токену на каждое задание в очереди
+	priority []*ScanJob
+	perUser  map[int64][]*ScanJob
+	users    []int64 // порядок обхода пользователей
+	next     int     // индекс пользователя, чье задание выдается следующим
+	size     int
+
+	capacity    int
+	userLimit   int
+	workers     int
+	running     int
+	avgDuration time.Duration
+}
+
+// Создаем очередь на capacity заданий, не больше userLimit от одного пользователя
+func NewScanQueue(capacity, userLimit, workers int) *ScanQueue {
+	return &ScanQueue{
+		ready:       make(chan struct{}, capacity),
+		perUser:     make(map[int64][]*ScanJob),
+		capacity:    capacity,
+		userLimit:   userLimit,
+		workers:     workers,
+		avgDuration: time.Minute,
+	}
+}
+
+// Добавляем задание и возвращаем его позицию (с 1)
+func (q *ScanQueue) Push(job *ScanJob) (int, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if q.size >= q.capacity {
+		return 0, ErrQueueFull
+	}
+
+	if job.Cached {
+		q.priority = append(q.priority, job)
+	} else {
+		if len(q.perUser[job.UserID]) >= q.userLimit {
+			return 0, ErrUserQueueFull
+		}
+		if len(q.perUser[job.UserID]) == 0 {
+			q.users = append(q.users, job.UserID)
+		}
+		q.perUser[job.UserID] = append(q.perUser[job.UserID], job)
+	}
+
+	q.size++
+	q.ready <- struct{}{}
+
+	return q.positionLocked(job), nil
+}
+
+// Убираем ожидающее задание из очереди. Возвращает false, если задания в
+// очереди нет или его вот-вот заберет воркер
+func (q *ScanQueue) Remove(job *ScanJob) bool {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	lane := q.priority
+	if !job.Cached {
+		lane = q.perUser[job.UserID]
+	}
+	remaining, found := removeJob(lane, job)
+	if !found {
+		return false
+	}
+
+	// Каждому заданию соответствует токен. Если свободных токенов нет, все они
+	// уже у воркеров, ждущих mutex в Pop, и один из них придет за этим заданием
+	select {
+	case <-q.ready:
+	default:
+		return false
+	}
+
+	switch {
+	case job.Cached:
+		q.priority = remaining
+	case len(remaining) == 0:
+		delete(q.perUser, job.UserID)
+		for i, userID := range q.users {
+			if userID != job.UserID {
+				continue
+			}
+			q.users = append(q.users[:i], q.users[i+1:]...)
+			if i < q.next {
+				q.next--
+			}
+			break
+		}
+		if len(q.users) > 0 {
+			q.next %= len(q.users)
+		} else {
+			q.next = 0
+		}
+	default:
+		q.perUser[job.UserID] = remaining
+	}
+
+	q.size--
+	return true
+}
+
+func removeJob(jobs []*ScanJob, job *ScanJob) ([]*ScanJob, bool) {
+	for i, queued := range jobs {
+		if queued == job {
+			return append(jobs[:i:i], jobs[i+1:]...), true
+		}
+	}
+	return jobs, false
+}
+
+// Ждем следующее задание
+func (q *ScanQueue) Pop(ctx context.Context) (*ScanJob, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-q.ready:
+	}
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	q.size--
+	q.running++
+
+	if len(q.priority) > 0 {
+		job := q.priority[0]
+		q.priority = q.priority[1:]
+		return job, nil
+	}
+
+	index := q.next % len(q.users)
+	userID := q.users[index]
+	jobs := q.perUser[userID]
+	job := jobs[0]
+
+	if len(jobs) == 1 {
+		// У пользователя больше нет заданий: убираем его из круга,
+		// следующий пользователь сдвигается на его место
+		delete(q.perUser, userID)
+		q.users = append(q.users[:index], q.users[index+1:]...)
+		q.next = index
+	} else {
+		q.perUser[userID] = jobs[1:]
+		q.next = index + 1
+	}
+
+	if len(q.users) > 0 {
+		q.next %= len(q.users)
+	} else {
+		q.next = 0
+	}
+
+	return job, nil
+}
+
+// Задание завершено: обновляем среднюю длительность для оценки ожидания
+func (q *ScanQueue) Done(job *ScanJob, duration time.Duration) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	q.running--
+
+	// Ответы из кэша почти мгновенны и только исказили бы оценку
+	if !job.Cached {
+		q.avgDuration = (q.avgDuration*4 + duration) / 5
+	}
+}
+
+// Порядок, в котором задания будут выданы (вызывать под mutex)
+func (q *ScanQueue) orderLocked() []*ScanJob {
+	order := append([]*ScanJob{}, q.priority...)
+
+	for round := 0; ; round++ {
+		added := false
+		for i := range q.users {
+			jobs := q.perUser[q.users[(q.next+i)%len(q.users)]]
+			if round < len(jobs) {
+				order = append(order, jobs[round])
+				added = true
+			}
+		}
+		if !added {
+			break
+		}
+	}
+
+	return order
+}
+
+func (q *ScanQueue) positionLocked(job *ScanJob) int {
+	for i, queued := range q.orderLocked() {
+		if queued == job {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// Ожидающие задания в порядке выдачи
+func (q *ScanQueue) Jobs() []*ScanJob {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	return q.orderLocked()
+}
+
+// Освободится ли воркер для задания на этой позиции сразу
+func (q *ScanQueue) StartsImmediately(position int) bool {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	return q.running+position <= q.workers
+}
+
+// Примерное время ожидания задания на позиции position
+func (q *ScanQueue) Estimate(position int) time.Duration {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	ahead := q.running + position - 1
+	return q.avgDuration * time.Duration(ahead) / time.Duration(q.workers)
+}
+
+// Размер очереди и число выполняющихся заданий
+func (q *ScanQueue) Depth() (queued, running int) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	return q.size, q.running
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestScanQueueRemove(t *testing.T) {
+	queue := NewScanQueue(10, 3, 1)
+
+	cached := &ScanJob{UserID: 1, Key: "cached", Cached: true}
+	first := &ScanJob{UserID: 1, Key: "first"}
+	second := &ScanJob{UserID: 2, Key: "second"}
+	third := &ScanJob{UserID: 3, Key: "third"}
+	for _, job := range []*ScanJob{cached, first, second, third} {
+		if _, err := queue.Push(job); err != nil {
+			t.Fatalf("Push(%s): %v", job.Key, err)
+		}
+	}
+
+	if !queue.Remove(cached) || !queue.Remove(second) {
+		t.Fatal("ожидающие задания должны убираться из очереди")
+	}
+	if queue.Remove(second) {
+		t.Fatal("повторное удаление должно возвращать false")
+	}
+
+	// Замена задания из кэша полным сканированием уходит в общую очередь
+	forced := &ScanJob{UserID: 1, Key: "cached", Force: true}
+	if _, err := queue.Push(forced); err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+
+	if queued, _ := queue.Depth(); queued != 3 {
+		t.Fatalf("в очереди %d заданий, ожидалось 3", queued)
+	}
+
+	var order []string
+	for i := 0; i < 3; i++ {
+		job, err := queue.Pop(context.Background())
+		if err != nil {
+			t.Fatalf("Pop: %v", err)
+		}
+		order = append(order, job.Key)
+	}
+
+	want := []string{"first", "third", "cached"}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Fatalf("порядок выдачи %v, ожидался %v", order, want)
+		}
+	}
+
+	// Токенов ровно столько, сколько заданий: очередь пуста
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if job, err := queue.Pop(ctx); err == nil {
+		t.Fatalf("очередь должна быть пуста, получено %s", job.Key)
+	}
+}
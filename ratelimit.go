@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// Token bucket: копит токены со скоростью rate в секунду, но не больше burst
+type TokenBucket struct {
+	mutex  sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// Создаем bucket, изначально заполненный до burst
+func NewTokenBucket(rate float64, burst int) *TokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &TokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// Пополняем токены за прошедшее время (вызывать под mutex)
+func (b *TokenBucket) refill(now time.Time) {
+	elapsed := now.Sub(b.last).Seconds()
+	if elapsed > 0 {
+		b.tokens = math.Min(b.burst, b.tokens+elapsed*b.rate)
+	}
+	b.last = now
+}
+
+// Время до появления следующего токена (вызывать под mutex)
+func (b *TokenBucket) delay() time.Duration {
+	if b.tokens >= 1 {
+		return 0
+	}
+	if b.rate <= 0 {
+		return time.Hour
+	}
+	return time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
+
+// Пробуем взять токен без ожидания. Если токена нет, возвращаем время до следующего
+func (b *TokenBucket) TryTake() (bool, time.Duration) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.refill(time.Now())
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+
+	return false, b.delay()
+}
+
+// Возвращаем токен, взятый для действия, которое так и не выполнилось
+func (b *TokenBucket) Refund() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.refill(time.Now())
+	b.tokens = math.Min(b.burst, b.tokens+1)
+}
+
+// Bucket полон: токены восстановились до burst, и его можно пересоздать без потерь
+func (b *TokenBucket) Full() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.refill(time.Now())
+	return b.tokens >= b.burst
+}
+
+// Текущая скорость пополнения
+func (b *TokenBucket) Rate() float64 {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return b.rate
+}
+
+// Меняем скорость пополнения, сохраняя накопленные токены
+func (b *TokenBucket) SetRate(rate float64) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.refill(time.Now())
+	b.rate = rate
+}
+
+// Сбрасываем накопленные токены, чтобы следующий запрос дождался пополнения
+func (b *TokenBucket) Drain() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.refill(time.Now())
+	b.tokens = math.Min(b.tokens, 0)
+}
+
+// Ждем токен, пока не отменят контекст
+func (b *TokenBucket) Wait(ctx context.Context) error {
+	for {
+		ok, wait := b.TryTake()
+		if ok {
+			return nil
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// Лимит для одного эндпоинта
+type Limit struct {
+	Rate  float64 // запросов в секунду
+	Burst int
+}
+
+// Rate limiter с отдельным bucket для каждого эндпоинта Steam
+type RateLimiter struct {
+	buckets map[Endpoint]*TokenBucket
+}
+
+// Создаем rate limiter
+func NewRateLimiter(limits map[Endpoint]Limit) *RateLimiter {
+	rl := &RateLimiter{
+		buckets: make(map[Endpoint]*TokenBucket),
+	}
+
+	for endpoint, limit := range limits {
+		rl.buckets[endpoint] = NewTokenBucket(limit.Rate, limit.Burst)
+	}
+
+	return rl
+}
+
+// Ждем разрешения на запрос к эндпоинту
+func (rl *RateLimiter) Wait(ctx context.Context, endpoint Endpoint) error {
+	bucket, exists := rl.buckets[endpoint]
+	if !exists {
+		return nil
+	}
+
+	return bucket.Wait(ctx)
+}
+
+// Bucket эндпоинта (nil, если эндпоинт не лимитируется)
+func (rl *RateLimiter) Bucket(endpoint Endpoint) *TokenBucket {
+	return rl.buckets[endpoint]
+}
+
+// Квота на действия одного пользователя Telegram: limit действий за period
+type UserQuota struct {
+	mutex   sync.Mutex
+	limit   int
+	period  time.Duration
+	buckets map[int64]*TokenBucket
+	pruned  time.Time
+}
+
+// Создаем квоту
+func NewUserQuota(limit int, period time.Duration) *UserQuota {
+	return &UserQuota{
+		limit:   limit,
+		period:  period,
+		buckets: make(map[int64]*TokenBucket),
+		pruned:  time.Now(),
+	}
+}
+
+// Проверяем квоту пользователя. Если она исчерпана, возвращаем время ожидания
+func (q *UserQuota) Allow(userID int64) (bool, time.Duration) {
+	q.mutex.Lock()
+	q.prune()
+	bucket, exists := q.buckets[userID]
+	if !exists {
+		bucket = NewTokenBucket(float64(q.limit)/q.period.Seconds(), q.limit)
+		q.buckets[userID] = bucket
+	}
+	q.mutex.Unlock()
+
+	return bucket.TryTake()
+}
+
+// Возвращаем пользователю единицу квоты (например, если очередь не приняла задание)
+func (q *UserQuota) Refund(userID int64) {
+	q.mutex.Lock()
+	bucket, exists := q.buckets[userID]
+	q.mutex.Unlock()
+
+	if exists {
+		bucket.Refund()
+	}
+}
+
+// Раз в period удаляем полные bucket'ы неактивных пользователей, чтобы словарь
+// не рос всю жизнь процесса (вызывать под mutex)
+func (q *UserQuota) prune() {
+	if time.Since(q.pruned) < q.period {
+		return
+	}
+	q.pruned = time.Now()
+
+	for userID, bucket := range q.buckets {
+		if bucket.Full() {
+			delete(q.buckets, userID)
+		}
+	}
+}
+
+// Форматируем время ожидания для сообщения пользователю
+func formatWait(d time.Duration) string {
+	if d < time.Minute {
+		return fmt.Sprintf("%d сек", int(math.Ceil(d.Seconds())))
+	}
+	return fmt.Sprintf("%d мин", int(math.Ceil(d.Minutes())))
+}
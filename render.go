@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// Цвета картинок в духе клиента Steam
+var (
+	colorBackground = color.RGBA{0x17, 0x1a, 0x21, 0xff}
+	colorPanel      = color.RGBA{0x1b, 0x28, 0x38, 0xff}
+	colorText       = color.RGBA{0xe6, 0xed, 0xf3, 0xff}
+	colorMuted      = color.RGBA{0x8f, 0x98, 0xa0, 0xff}
+	colorAccent     = color.RGBA{0x66, 0xc0, 0xf4, 0xff}
+	colorPrice      = color.RGBA{0xa4, 0xd0, 0x07, 0xff}
+)
+
+// Шрифты Go (с кириллицей) разбираем один раз; Face не потокобезопасен,
+// поэтому для каждой картинки создается свой
+var (
+	fontsOnce   sync.Once
+	regularFont *opentype.Font
+	boldFont    *opentype.Font
+)
+
+func loadFonts() {
+	fontsOnce.Do(func() {
+		regularFont, _ = opentype.Parse(goregular.TTF)
+		boldFont, _ = opentype.Parse(gobold.TTF)
+	})
+}
+
+// Создаем начертание нужного размера (в пикселях)
+func newFace(bold bool, size float64) font.Face {
+	loadFonts()
+
+	parsed := regularFont
+	if bold {
+		parsed = boldFont
+	}
+
+	face, err := opentype.NewFace(parsed, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		panic(err) // встроенные шрифты всегда корректны
+	}
+	return face
+}
+
+// Пишем текст; (x, y) - левый край базовой линии
+func drawText(dst draw.Image, face font.Face, x, y int, text string, c color.Color) {
+	drawer := font.Drawer{
+		Dst:  dst,
+		Src:  image.NewUniform(c),
+		Face: face,
+		Dot:  fixed.P(x, y),
+	}
+	drawer.DrawString(text)
+}
+
+// Ширина текста в пикселях
+func textWidth(face font.Face, text string) int {
+	return font.MeasureString(face, text).Ceil()
+}
+
+// Обрезаем текст с многоточием, чтобы он поместился в width пикселей
+func fitText(face font.Face, text string, width int) string {
+	if textWidth(face, text) <= width {
+		return text
+	}
+
+	runes := []rune(text)
+	for len(runes) > 0 {
+		runes = runes[:len(runes)-1]
+		candidate := strings.TrimSpace(string(runes)) + "…"
+		if textWidth(face, candidate) <= width {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// Заливаем прямоугольник
+func fillRect(dst draw.Image, rect image.Rectangle, c color.Color) {
+	draw.Draw(dst, rect, image.NewUniform(c), image.Point{}, draw.Src)
+}
+
+// Рамка толщиной width внутри прямоугольника
+func strokeRect(dst draw.Image, rect image.Rectangle, width int, c color.Color) {
+	fillRect(dst, image.Rect(rect.Min.X, rect.Min.Y, rect.Max.X, rect.Min.Y+width), c)
+	fillRect(dst, image.Rect(rect.Min.X, rect.Max.Y-width, rect.Max.X, rect.Max.Y), c)
+	fillRect(dst, image.Rect(rect.Min.X, rect.Min.Y, rect.Min.X+width, rect.Max.Y), c)
+	fillRect(dst, image.Rect(rect.Max.X-width, rect.Min.Y, rect.Max.X, rect.Max.Y), c)
+}
+
+// Цвет из шестнадцатеричной строки Steam ("eb4b4b"); fallback, если строка некорректна
+func parseHexColor(hex string, fallback color.RGBA) color.RGBA {
+	hex = strings.TrimPrefix(hex, "#")
+	if len(hex) != 6 {
+		return fallback
+	}
+
+	value, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return fallback
+	}
+	return color.RGBA{uint8(value >> 16), uint8(value >> 8), uint8(value), 0xff}
+}
+
+// Кодируем картинку в PNG
+func encodePNG(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Цена для картинок: шрифт не содержит знака рубля
+func formatRubles(value float64) string {
+	text := strconv.FormatFloat(value, 'f', 2, 64)
+
+	// Разделяем тысячи пробелами: 1234567.89 -> 1 234 567.89
+	whole, fraction, _ := strings.Cut(text, ".")
+	var b strings.Builder
+	for i, digit := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 && whole[i-1] != '-' {
+			b.WriteByte(' ')
+		}
+		b.WriteRune(digit)
+	}
+	return b.String() + "." + fraction + " руб."
+}
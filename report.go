@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Результат сканирования. После создания не изменяется: его разделяют кэш,
+// отчеты и все чаты, получившие результат, поэтому сортировка и фильтрация
+// всегда работают с копиями Items
+type ScanResult struct {
+	SteamID         string          `json:"steam_id"`
+	AppID           string          `json:"app_id"`
+	Items           []InventoryItem `json:"items"`
+	TotalCount      int             `json:"total_count"`      // предметов в инвентаре по данным Steam
+	FetchedCount    int             `json:"fetched_count"`    // сколько предметов загружено
+	MarketableCount int             `json:"marketable_count"` // сколько загруженных можно продать
+	PricedLimit     int             `json:"priced_limit"`     // цены запрашивались только для первых N, 0 - для всех
+	FetchPartial    bool            `json:"fetch_partial"`    // загрузка страниц прервалась
+	PricingPartial  bool            `json:"pricing_partial"`  // запрос цен прервался
+	ScannedAt       time.Time       `json:"scanned_at"`
+	Duration        time.Duration   `json:"duration"`
+}
+
+// Неполный ли результат
+func (r *ScanResult) Partial() bool {
+	return r.PricedLimit > 0 || r.FetchPartial || r.PricingPartial
+}
+
+// Копия предметов, отсортированная по убыванию цены
+func (r *ScanResult) ItemsByPrice() []InventoryItem {
+	items := append([]InventoryItem(nil), r.Items...)
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].PriceValue > items[j].PriceValue
+	})
+	return items
+}
+
+// Примерный размер результата в памяти
+func (r *ScanResult) Size() int64 {
+	size := int64(256)
+	for _, item := range r.Items {
+		size += int64(len(item.Name)+len(item.MarketName)+len(item.MarketHashName)+len(item.Type)+len(item.Price)) + 160
+		size += int64(len(item.AssetID) + len(item.IconURL) + len(item.IconURLLarge) + len(item.InspectLink) + len(item.NameColor))
+		for _, tag := range item.Tags {
+			size += int64(len(tag.Category)+len(tag.InternalName)+len(tag.LocalizedCategoryName)+len(tag.LocalizedTagName)) + 64
+		}
+	}
+	return size
+}
+
+// Отчет по результату сканирования
+type Report struct {
+	Result     *ScanResult
+	TotalValue float64
+	MinItem    InventoryItem
+	MaxItem    InventoryItem
+	TopItems   []InventoryItem
+	Breakdown  Breakdown
+}
+
+// Сколько самых дорогих предметов показываем в отчете
+const topItemsCount = 5
+
+func BuildReport(result *ScanResult) Report {
+	report := Report{Result: result}
+
+	for i, item := range result.Items {
+		if i == 0 || item.PriceValue < report.MinItem.PriceValue {
+			report.MinItem = item
+		}
+		if i == 0 || item.PriceValue > report.MaxItem.PriceValue {
+			report.MaxItem = item
+		}
+		report.TotalValue += item.Value()
+	}
+
+	report.TopItems = result.ItemsByPrice()
+	if len(report.TopItems) > topItemsCount {
+		report.TopItems = report.TopItems[:topItemsCount]
+	}
+
+	report.Breakdown = BuildBreakdown(result)
+
+	return report
+}
+
+// Текст отчета. source - пометка о происхождении данных ("из кэша" и т.п.)
+func (r Report) Format(source string) string {
+	result := r.Result
+
+	title := fmt.Sprintf("📊 *Статистика инвентаря %s*", result.SteamID)
+	if source != "" {
+		title += " (" + source + ")"
+	}
+
+	var b strings.Builder
+	b.WriteString(title + "\n\n")
+	fmt.Fprintf(&b, "🎮 Игра: %s\n", getGameName(result.AppID))
+	fmt.Fprintf(&b, "📦 Всего предметов: %d\n", result.TotalCount)
+	fmt.Fprintf(&b, "💰 Продаваемых: %d\n", result.MarketableCount)
+	fmt.Fprintf(&b, "🏷 С ценой: %d\n", len(result.Items))
+	fmt.Fprintf(&b, "💵 Общая стоимость: %.2f ₽\n", r.TotalValue)
+
+	b.WriteString("\n📈 *Ценовая статистика:*\n")
+	fmt.Fprintf(&b, "• Минимальная: %.2f ₽ (%s)\n", r.MinItem.PriceValue, r.MinItem.Name)
+	fmt.Fprintf(&b, "• Максимальная: %.2f ₽ (%s)\n", r.MaxItem.PriceValue, r.MaxItem.Name)
+
+	if result.Partial() {
+		b.WriteString("\n⚠️ *Неполные данные:*\n")
+		if result.FetchPartial {
+			fmt.Fprintf(&b, "• Загружено %d из %d предметов\n", result.FetchedCount, result.TotalCount)
+		}
+		if result.PricedLimit > 0 {
+			fmt.Fprintf(&b, "• Цены получены только для первых %d предметов\n", result.PricedLimit)
+		}
+		if result.PricingPartial {
+			b.WriteString("• Получение цен прервано\n")
+		}
+	}
+
+	fmt.Fprintf(&b, "\n⏱ Время сканирования: %v\n", result.Duration.Round(time.Second))
+	fmt.Fprintf(&b, "🕐 Данные получены %s", formatAge(time.Since(result.ScannedAt)))
+
+	return b.String()
+}
+
+// Текст топа самых дорогих предметов
+func (r Report) FormatTopItems() string {
+	text := fmt.Sprintf("🏆 *Топ-%d самых дорогих предметов:*\n\n", len(r.TopItems))
+
+	for i, item := range r.TopItems {
+		text += fmt.Sprintf("%d. *%s*\n   💰 %.2f ₽\n\n", i+1, escapeMarkdown(item.Name), item.PriceValue)
+	}
+
+	return text + "Нажмите номер, чтобы открыть карточку предмета."
+}
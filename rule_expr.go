@@ -0,0 +1,441 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Метрики снимка, доступные в правилах
+var profileMetrics = map[string]string{
+	"total_value":      "стоимость инвентаря, ₽",
+	"item_count":       "количество предметов",
+	"change_24h":       "изменение стоимости за сутки, %",
+	"change_7d":        "изменение стоимости за неделю, %",
+	"value_change_24h": "изменение стоимости за сутки, ₽",
+}
+
+// Метрики предмета: правило с ними выполняется, если подходит хотя бы один предмет
+var itemMetrics = map[string]string{
+	"item.price":      "цена предмета, ₽",
+	"item.volume":     "продано на площадке за сутки",
+	"item.quantity":   "количество в стопке",
+	"item.value":      "стоимость стопки, ₽",
+	"item.change_24h": "изменение цены предмета за сутки, %",
+}
+
+// Метрики нет в снимке (например, нет снимка суточной давности)
+var errNoMetric = errors.New("нет данных для метрики")
+
+// Значение метрики по имени
+type ruleEnv func(name string) (float64, bool)
+
+// Узел выражения. Условия возвращают 1 или 0
+type ruleExpr interface {
+	eval(env ruleEnv) (float64, error)
+}
+
+type ruleNumber float64
+
+func (n ruleNumber) eval(ruleEnv) (float64, error) {
+	return float64(n), nil
+}
+
+type ruleMetric string
+
+func (m ruleMetric) eval(env ruleEnv) (float64, error) {
+	value, ok := env(string(m))
+	if !ok {
+		return 0, errNoMetric
+	}
+	return value, nil
+}
+
+type ruleUnary struct {
+	op string
+	x  ruleExpr
+}
+
+func (u ruleUnary) eval(env ruleEnv) (float64, error) {
+	x, err := u.x.eval(env)
+	if err != nil {
+		return 0, err
+	}
+	if u.op == "-" {
+		return -x, nil
+	}
+	return boolValue(x == 0), nil // not
+}
+
+type ruleBinary struct {
+	op   string
+	x, y ruleExpr
+}
+
+func (b ruleBinary) eval(env ruleEnv) (float64, error) {
+	x, err := b.x.eval(env)
+	if err != nil {
+		return 0, err
+	}
+
+	// Логические операции вычисляются сокращенно
+	switch b.op {
+	case "and":
+		if x == 0 {
+			return 0, nil
+		}
+		return b.y.eval(env)
+	case "or":
+		if x != 0 {
+			return 1, nil
+		}
+		return b.y.eval(env)
+	}
+
+	y, err := b.y.eval(env)
+	if err != nil {
+		return 0, err
+	}
+
+	switch b.op {
+	case "+":
+		return x + y, nil
+	case "-":
+		return x - y, nil
+	case "*":
+		return x * y, nil
+	case "/":
+		if y == 0 {
+			return 0, errNoMetric
+		}
+		return x / y, nil
+	case "<":
+		return boolValue(x < y), nil
+	case "<=":
+		return boolValue(x <= y), nil
+	case ">":
+		return boolValue(x > y), nil
+	case ">=":
+		return boolValue(x >= y), nil
+	case "==":
+		return boolValue(math.Abs(x-y) < 1e-9), nil
+	case "!=":
+		return boolValue(math.Abs(x-y) >= 1e-9), nil
+	}
+	return 0, fmt.Errorf("неизвестная операция %s", b.op)
+}
+
+func boolValue(condition bool) float64 {
+	if condition {
+		return 1
+	}
+	return 0
+}
+
+// Скомпилированное правило
+type CompiledRule struct {
+	Text      string
+	expr      ruleExpr
+	UsesItems bool     // есть метрики item.*
+	Metrics   []string // метрики профиля, которые показываем в уведомлении
+}
+
+// Разбираем и проверяем правило: все метрики известны, а выражение - условие,
+// например "change_24h <= -10" или "item.volume < 5 and item.price > 1000"
+func CompileRule(text string) (*CompiledRule, error) {
+	tokens, err := tokenizeRule(text)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &ruleParser{tokens: tokens, metrics: make(map[string]bool)}
+	expr, isCondition, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek() != "" {
+		return nil, fmt.Errorf("лишнее в конце выражения: %s", p.peek())
+	}
+	if !isCondition {
+		return nil, errors.New("правило должно быть условием, например total_value < 10000")
+	}
+
+	rule := &CompiledRule{Text: strings.TrimSpace(text), expr: expr}
+	for name := range p.metrics {
+		if _, isItem := itemMetrics[name]; isItem {
+			rule.UsesItems = true
+		} else {
+			rule.Metrics = append(rule.Metrics, name)
+		}
+	}
+	sort.Strings(rule.Metrics)
+
+	return rule, nil
+}
+
+// Проверяем правило. Для правил с метриками предметов возвращаем индексы
+// подходящих предметов; правило выполняется, если такой есть хотя бы один.
+// Если нужной метрики нет, правило не выполняется
+func (r *CompiledRule) Evaluate(profile ruleEnv, items []ruleEnv) (bool, []int) {
+	if !r.UsesItems {
+		value, err := r.expr.eval(profile)
+		return err == nil && value != 0, nil
+	}
+
+	var matched []int
+	for i, item := range items {
+		env := func(name string) (float64, bool) {
+			if value, ok := item(name); ok {
+				return value, true
+			}
+			return profile(name)
+		}
+		if value, err := r.expr.eval(env); err == nil && value != 0 {
+			matched = append(matched, i)
+		}
+	}
+	return len(matched) > 0, matched
+}
+
+// Разбор выражения рекурсивным спуском. Каждый уровень возвращает узел
+// и признак того, что это условие, а не число
+type ruleParser struct {
+	tokens  []string
+	pos     int
+	metrics map[string]bool
+}
+
+func (p *ruleParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *ruleParser) next() string {
+	token := p.peek()
+	p.pos++
+	return token
+}
+
+func (p *ruleParser) parseOr() (ruleExpr, bool, error) {
+	return p.parseLogical("or", p.parseAnd)
+}
+
+func (p *ruleParser) parseAnd() (ruleExpr, bool, error) {
+	return p.parseLogical("and", p.parseNot)
+}
+
+func (p *ruleParser) parseLogical(op string, operand func() (ruleExpr, bool, error)) (ruleExpr, bool, error) {
+	x, isCondition, err := operand()
+	if err != nil {
+		return nil, false, err
+	}
+
+	for p.peek() == op {
+		p.next()
+		y, yCondition, err := operand()
+		if err != nil {
+			return nil, false, err
+		}
+		if !isCondition || !yCondition {
+			return nil, false, fmt.Errorf("%s соединяет условия, а не числа", op)
+		}
+		x = ruleBinary{op: op, x: x, y: y}
+	}
+
+	return x, isCondition, nil
+}
+
+func (p *ruleParser) parseNot() (ruleExpr, bool, error) {
+	if p.peek() != "not" {
+		return p.parseComparison()
+	}
+
+	p.next()
+	x, isCondition, err := p.parseNot()
+	if err != nil {
+		return nil, false, err
+	}
+	if !isCondition {
+		return nil, false, errors.New("not применяется к условию")
+	}
+	return ruleUnary{op: "not", x: x}, true, nil
+}
+
+func (p *ruleParser) parseComparison() (ruleExpr, bool, error) {
+	x, isCondition, err := p.parseSum()
+	if err != nil {
+		return nil, false, err
+	}
+
+	switch op := p.peek(); op {
+	case "<", "<=", ">", ">=", "==", "!=":
+		p.next()
+		y, yCondition, err := p.parseSum()
+		if err != nil {
+			return nil, false, err
+		}
+		if isCondition || yCondition {
+			return nil, false, fmt.Errorf("%s сравнивает числа, а не условия", op)
+		}
+		return ruleBinary{op: op, x: x, y: y}, true, nil
+	}
+
+	return x, isCondition, nil
+}
+
+func (p *ruleParser) parseSum() (ruleExpr, bool, error) {
+	return p.parseArithmetic([]string{"+", "-"}, p.parseProduct)
+}
+
+func (p *ruleParser) parseProduct() (ruleExpr, bool, error) {
+	return p.parseArithmetic([]string{"*", "/"}, p.parseUnary)
+}
+
+func (p *ruleParser) parseArithmetic(ops []string, operand func() (ruleExpr, bool, error)) (ruleExpr, bool, error) {
+	x, isCondition, err := operand()
+	if err != nil {
+		return nil, false, err
+	}
+
+	for {
+		op := p.peek()
+		if op != ops[0] && op != ops[1] {
+			return x, isCondition, nil
+		}
+		p.next()
+
+		y, yCondition, err := operand()
+		if err != nil {
+			return nil, false, err
+		}
+		if isCondition || yCondition {
+			return nil, false, fmt.Errorf("%s применяется к числам, а не к условиям", op)
+		}
+		x = ruleBinary{op: op, x: x, y: y}
+	}
+}
+
+func (p *ruleParser) parseUnary() (ruleExpr, bool, error) {
+	if p.peek() != "-" {
+		return p.parsePrimary()
+	}
+
+	p.next()
+	x, isCondition, err := p.parseUnary()
+	if err != nil {
+		return nil, false, err
+	}
+	if isCondition {
+		return nil, false, errors.New("минус применяется к числу, а не к условию")
+	}
+	return ruleUnary{op: "-", x: x}, false, nil
+}
+
+func (p *ruleParser) parsePrimary() (ruleExpr, bool, error) {
+	token := p.next()
+
+	switch {
+	case token == "":
+		return nil, false, errors.New("выражение оборвалось")
+	case token == "(":
+		x, isCondition, err := p.parseOr()
+		if err != nil {
+			return nil, false, err
+		}
+		if p.next() != ")" {
+			return nil, false, errors.New("не закрыта скобка")
+		}
+		return x, isCondition, nil
+	case unicode.IsDigit(rune(token[0])) || token[0] == '.':
+		value, err := strconv.ParseFloat(token, 64)
+		if err != nil {
+			return nil, false, fmt.Errorf("не число: %s", token)
+		}
+		return ruleNumber(value), false, nil
+	case isRuleIdentifier(token):
+		_, isProfile := profileMetrics[token]
+		_, isItem := itemMetrics[token]
+		if !isProfile && !isItem {
+			return nil, false, fmt.Errorf("неизвестная метрика: %s", token)
+		}
+		p.metrics[token] = true
+		return ruleMetric(token), false, nil
+	}
+
+	return nil, false, fmt.Errorf("неожиданное %s", token)
+}
+
+func isRuleIdentifier(token string) bool {
+	r := rune(token[0])
+	return unicode.IsLetter(r) || r == '_'
+}
+
+// Разбиваем правило на числа, имена метрик, операции и скобки.
+// Слова and/or/not можно писать и как &&, ||, !
+func tokenizeRule(text string) ([]string, error) {
+	var tokens []string
+	runes := []rune(strings.ToLower(text))
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case unicode.IsDigit(r) || r == '.' && i+1 < len(runes) && unicode.IsDigit(runes[i+1]):
+			start := i
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, string(runes[start:i]))
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_' || runes[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, string(runes[start:i]))
+		default:
+			two := ""
+			if i+1 < len(runes) {
+				two = string(runes[i : i+2])
+			}
+			switch two {
+			case "<=", ">=", "==", "!=":
+				tokens = append(tokens, two)
+				i += 2
+				continue
+			case "&&":
+				tokens = append(tokens, "and")
+				i += 2
+				continue
+			case "||":
+				tokens = append(tokens, "or")
+				i += 2
+				continue
+			}
+
+			switch r {
+			case '<', '>', '+', '-', '*', '/', '(', ')':
+				tokens = append(tokens, string(r))
+			case '!':
+				tokens = append(tokens, "not")
+			case '=':
+				tokens = append(tokens, "==")
+			default:
+				return nil, fmt.Errorf("непонятный символ: %c", r)
+			}
+			i++
+		}
+	}
+
+	if len(tokens) == 0 {
+		return nil, errors.New("пустое правило")
+	}
+	return tokens, nil
+}
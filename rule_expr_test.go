@@ -0,0 +1,136 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCompileRule(t *testing.T) {
+	tests := []struct {
+		text  string
+		valid bool
+	}{
+		// Примеры из справки /rule и README
+		{"change_24h <= -10", true},
+		{"item.volume < 5 and item.price > 1000", true},
+		{"total_value > 100000 or item.change_24h >= 20", true},
+
+		{"not change_7d > 0", true},
+		{"(total_value - 1000) / 2 >= item_count * 3", true},
+		{"change_24h < -5 && !(item_count == 0) || value_change_24h != 0", true},
+		{"TOTAL_VALUE > 1", true},
+
+		{"", false},
+		{"total_value", false},         // не условие
+		{"price > 10", false},          // неизвестная метрика
+		{"item.name == 1", false},      // неизвестная метрика предмета
+		{"total_value >", false},       // оборванный оператор
+		{"total_value > 1 and", false}, // оборванный оператор
+		{"not", false},                 // оборванный оператор
+		{"1 < total_value < 3", false}, // цепочка сравнений
+		{"(total_value > 1", false},    // не закрыта скобка
+		{"total_value > 1)", false},    // лишняя скобка
+		{"((total_value > 1)", false},  // не закрыта скобка
+		{"total_value > 1 total_value", false},
+		{"total_value + 1 and item_count > 1", false}, // and соединяет условия
+		{"-(total_value > 1)", false},                 // минус от условия
+		{"(total_value > 1) > 0", false},              // сравнение условий
+		{"not total_value", false},                    // not от числа
+		{"total_value > 1.2.3", false},                // не число
+		{"total_value > 1 ; drop", false},             // непонятный символ
+	}
+
+	for _, test := range tests {
+		_, err := CompileRule(test.text)
+		if test.valid && err != nil {
+			t.Errorf("CompileRule(%q): %v", test.text, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("CompileRule(%q): ожидалась ошибка", test.text)
+		}
+	}
+}
+
+func TestRulePrecedence(t *testing.T) {
+	// total_value = 1, item_count = 0: истинное и ложное условия
+	env := mapEnv(map[string]float64{"total_value": 1, "item_count": 0, "change_24h": -12, "change_7d": 4})
+
+	tests := []struct {
+		text string
+		want bool
+	}{
+		// and связывает сильнее or
+		{"total_value == 1 or item_count == 1 and total_value == 2", true},
+		{"(total_value == 1 or item_count == 1) and total_value == 2", false},
+		{"item_count == 1 and total_value == 2 or total_value == 1", true},
+
+		// not связывает сильнее and и or
+		{"not item_count == 1 and total_value == 1", true},
+		{"not total_value == 1 or total_value == 1", true},
+		{"not (total_value == 1 or item_count == 1)", false},
+		{"not not total_value == 1", true},
+
+		// Арифметика: * раньше +, унарный минус
+		{"total_value + 2 * 3 == 7", true},
+		{"(total_value + 2) * 3 == 9", true},
+		{"-change_24h > 10", true},
+		{"change_24h - -2 == -10", true},
+
+		// Примеры из справки
+		{"change_24h <= -10", true},
+		{"change_7d <= -10", false},
+
+		// Нет метрики - правило не выполняется, даже под not
+		{"not value_change_24h > 0", false},
+		{"total_value / item_count > 0", false},
+	}
+
+	for _, test := range tests {
+		rule, err := CompileRule(test.text)
+		if err != nil {
+			t.Fatalf("CompileRule(%q): %v", test.text, err)
+		}
+		if got, _ := rule.Evaluate(env, nil); got != test.want {
+			t.Errorf("%q = %v, ожидалось %v", test.text, got, test.want)
+		}
+	}
+}
+
+func TestRuleItems(t *testing.T) {
+	rule, err := CompileRule("item.volume < 5 and item.price > 1000")
+	if err != nil {
+		t.Fatalf("CompileRule: %v", err)
+	}
+	if !rule.UsesItems || len(rule.Metrics) != 0 {
+		t.Fatalf("UsesItems = %v, Metrics = %v", rule.UsesItems, rule.Metrics)
+	}
+
+	items := []ruleEnv{
+		mapEnv(map[string]float64{"item.volume": 2, "item.price": 500}),
+		mapEnv(map[string]float64{"item.volume": 2, "item.price": 1500}),
+		mapEnv(map[string]float64{"item.volume": 10, "item.price": 5000}),
+		mapEnv(map[string]float64{"item.volume": 0, "item.price": 1001}),
+	}
+
+	matched, indexes := rule.Evaluate(mapEnv(nil), items)
+	if !matched || !reflect.DeepEqual(indexes, []int{1, 3}) {
+		t.Errorf("Evaluate = %v, %v; ожидалось true, [1 3]", matched, indexes)
+	}
+
+	// Метрики профиля доступны и в правилах с предметами
+	mixed, err := CompileRule("total_value > 100000 or item.change_24h >= 20")
+	if err != nil {
+		t.Fatalf("CompileRule: %v", err)
+	}
+	if !reflect.DeepEqual(mixed.Metrics, []string{"total_value"}) {
+		t.Errorf("Metrics = %v", mixed.Metrics)
+	}
+
+	profile := mapEnv(map[string]float64{"total_value": 200000})
+	if matched, indexes := mixed.Evaluate(profile, items[:2]); !matched || len(indexes) != 2 {
+		t.Errorf("Evaluate = %v, %v; ожидались оба предмета", matched, indexes)
+	}
+	if matched, _ := mixed.Evaluate(profile, nil); matched {
+		t.Error("правило с метриками предметов не выполняется без предметов")
+	}
+}
@@ -0,0 +1,359 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	maxRulesPerChat = 20
+	// Сколько подходящих предметов показываем в уведомлении
+	ruleItemsShown = 10
+)
+
+// Правило чата. Проверяется после каждого сканирования профилей, за которыми следит чат
+type Rule struct {
+	ID        string          `json:"id"`
+	ChatID    int64           `json:"chat_id"`
+	UserID    int64           `json:"user_id"`
+	Text      string          `json:"text"`
+	CreatedAt time.Time       `json:"created_at"`
+	Active    map[string]bool `json:"active,omitempty"`   // профиль -> правило выполнялось при прошлой проверке
+	Triggers  []Trigger       `json:"triggers,omitempty"` // история срабатываний для сводки
+}
+
+// Хранилище правил: в памяти и в постоянном хранилище (пространство имен rules)
+type RuleStore struct {
+	rules *Store[Rule]
+}
+
+func NewRuleStore(backend CacheBackend) *RuleStore {
+	return &RuleStore{rules: NewStore[Rule]("правил", backend)}
+}
+
+func (s *RuleStore) Add(rule Rule) {
+	s.rules.Set(rule.ID, rule)
+}
+
+// Удаляем правило чата. Возвращает false, если такого правила в чате нет
+func (s *RuleStore) Remove(chatID int64, id string) bool {
+	removed := false
+	s.rules.Update(id, func(rule *Rule, exists bool) storeAction {
+		if !exists || rule.ChatID != chatID {
+			return storeKeep
+		}
+		removed = true
+		return storeDelete
+	})
+	return removed
+}
+
+// Правила чата в порядке создания
+func (s *RuleStore) List(chatID int64) []Rule {
+	rules := s.rules.Values(func(rule Rule) bool {
+		return rule.ChatID == chatID
+	})
+
+	sort.Slice(rules, func(i, j int) bool {
+		return rules[i].CreatedAt.Before(rules[j].CreatedAt)
+	})
+	return rules
+}
+
+// Правила пользователя во всех чатах в порядке создания
+func (s *RuleStore) ListByUser(userID int64) []Rule {
+	rules := s.rules.Values(func(rule Rule) bool {
+		return rule.UserID == userID
+	})
+
+	sort.Slice(rules, func(i, j int) bool {
+		return rules[i].CreatedAt.Before(rules[j].CreatedAt)
+	})
+	return rules
+}
+
+// Добавляем срабатывание правила в его историю
+func (s *RuleStore) RecordTrigger(id string, trigger Trigger) {
+	s.rules.Update(id, func(rule *Rule, exists bool) storeAction {
+		if !exists {
+			return storeKeep
+		}
+		rule.Triggers = appendTrigger(rule.Triggers, trigger)
+		return storeSave
+	})
+}
+
+// Запоминаем, выполнялось ли правило для профиля. Возвращает прежнее состояние
+func (s *RuleStore) SetActive(id, profile string, active bool) bool {
+	previous := active
+	s.rules.Update(id, func(rule *Rule, exists bool) storeAction {
+		if !exists {
+			return storeKeep
+		}
+
+		previous = rule.Active[profile]
+		if previous == active {
+			return storeKeep
+		}
+
+		// Копия карты: прежнюю запись могут читать без блокировки
+		updated := make(map[string]bool, len(rule.Active)+1)
+		for key, value := range rule.Active {
+			updated[key] = value
+		}
+		if active {
+			updated[profile] = true
+		} else {
+			delete(updated, profile)
+		}
+		rule.Active = updated
+		return storeSave
+	})
+	return previous
+}
+
+func (s *RuleStore) Close() {
+	s.rules.Close()
+}
+
+// Метрики профиля и его предметов по снимку и истории снимков
+func snapshotEnv(snapshot *Snapshot, history []*Snapshot) (ruleEnv, []ruleEnv) {
+	profile := map[string]float64{
+		"total_value": snapshot.TotalValue,
+	}
+
+	count := 0
+	for _, item := range snapshot.Items {
+		count += item.Quantity
+	}
+	profile["item_count"] = float64(count)
+
+	// Снимок, сделанный period назад (но не вдвое раньше - иначе сравнение бессмысленно)
+	before := func(period time.Duration) (*Snapshot, bool) {
+		old, found := snapshotBefore(history, snapshot.TakenAt.Add(-period))
+		if !found || old.TakenAt.Before(snapshot.TakenAt.Add(-2*period)) || old.TotalValue == 0 {
+			return nil, false
+		}
+		return old, true
+	}
+
+	dayAgo, hasDay := before(24 * time.Hour)
+	if hasDay {
+		profile["change_24h"] = (snapshot.TotalValue - dayAgo.TotalValue) / dayAgo.TotalValue * 100
+		profile["value_change_24h"] = snapshot.TotalValue - dayAgo.TotalValue
+	}
+	if weekAgo, found := before(7 * 24 * time.Hour); found {
+		profile["change_7d"] = (snapshot.TotalValue - weekAgo.TotalValue) / weekAgo.TotalValue * 100
+	}
+
+	oldPrices := make(map[string]float64)
+	if hasDay {
+		for _, item := range dayAgo.Items {
+			oldPrices[item.MarketHashName] = item.Price
+		}
+	}
+
+	items := make([]ruleEnv, len(snapshot.Items))
+	for i, item := range snapshot.Items {
+		metrics := map[string]float64{
+			"item.price":    item.Price,
+			"item.volume":   float64(item.Volume),
+			"item.quantity": float64(item.Quantity),
+			"item.value":    item.Value(),
+		}
+		if old, found := oldPrices[item.MarketHashName]; found && old > 0 {
+			metrics["item.change_24h"] = (item.Price - old) / old * 100
+		}
+		items[i] = mapEnv(metrics)
+	}
+
+	return mapEnv(profile), items
+}
+
+func mapEnv(metrics map[string]float64) ruleEnv {
+	return func(name string) (float64, bool) {
+		value, ok := metrics[name]
+		return value, ok
+	}
+}
+
+func ruleID() string {
+	return "rule_" + strconv.FormatInt(time.Now().UnixNano(), 36)
+}
+
+func (tb *TelegramBot) handleRuleCommand(chatID, userID int64, text string) {
+	expression := strings.TrimSpace(strings.TrimPrefix(text, "/rule"))
+	if expression == "" {
+		tb.sendMessage(chatID, ruleHelp())
+		return
+	}
+
+	compiled, err := CompileRule(expression)
+	if err != nil {
+		tb.sendMessage(chatID, "❌ Ошибка в правиле: "+escapeMarkdown(err.Error())+"\n\nСправка: /rule")
+		return
+	}
+
+	if len(tb.rules.List(chatID)) >= maxRulesPerChat {
+		tb.sendMessage(chatID, fmt.Sprintf("🚦 В чате уже %d правил. Удалите лишние: /rules", maxRulesPerChat))
+		return
+	}
+
+	tb.rules.Add(Rule{
+		ID:        ruleID(),
+		ChatID:    chatID,
+		UserID:    userID,
+		Text:      compiled.Text,
+		CreatedAt: time.Now(),
+	})
+
+	text = "📐 Правило добавлено: `" + compiled.Text + "`\nОно проверяется после каждого сканирования профилей, за которыми следит этот чат."
+	if len(tb.scheduler.List(watchKind, func(task ScheduledTask) bool { return task.ChatID == chatID })) == 0 {
+		text += "\n\nВ чате пока нет наблюдаемых профилей. Добавьте: /watch <steam\\_id>"
+	}
+	tb.sendMessage(chatID, text)
+}
+
+// Справка по языку правил
+func ruleHelp() string {
+	var b strings.Builder
+	b.WriteString("📐 *Правила*\n\nИспользование: /rule <условие>\n\n*Метрики профиля:*\n")
+	writeMetrics(&b, profileMetrics)
+	b.WriteString("\n*Метрики предмета* (правило срабатывает, если подходит хотя бы один предмет):\n")
+	writeMetrics(&b, itemMetrics)
+	b.WriteString("\nОперации: `< <= > >= == != + - * / and or not`, скобки\n\n*Примеры:*\n")
+	b.WriteString("`/rule change_24h <= -10`\n`/rule item.volume < 5 and item.price > 1000`\n`/rule total_value > 100000 or item.change_24h >= 20`\n\nСписок правил: /rules")
+	return b.String()
+}
+
+func writeMetrics(b *strings.Builder, metrics map[string]string) {
+	names := make([]string, 0, len(metrics))
+	for name := range metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(b, "• `%s` - %s\n", name, metrics[name])
+	}
+}
+
+// Список правил чата с кнопками удаления
+func (tb *TelegramBot) renderRules(chatID int64) (string, *tgbotapi.InlineKeyboardMarkup) {
+	rules := tb.rules.List(chatID)
+	if len(rules) == 0 {
+		return "📐 В этом чате нет правил.\nСправка: /rule", nil
+	}
+
+	var b strings.Builder
+	b.WriteString("📐 *Правила чата*\n\n")
+
+	callbacks := make([]string, 0, len(rules))
+	for i, rule := range rules {
+		fmt.Fprintf(&b, "%d. `%s`", i+1, rule.Text)
+		if len(rule.Active) > 0 {
+			b.WriteString(" - выполняется")
+		}
+		b.WriteString("\n")
+		callbacks = append(callbacks, "ruledel_"+rule.ID)
+	}
+	b.WriteString("\nНажмите номер, чтобы удалить правило.")
+
+	return b.String(), deleteKeyboard(callbacks)
+}
+
+func (tb *TelegramBot) sendRules(chatID int64) {
+	text, keyboard := tb.renderRules(chatID)
+	tb.sendList(chatID, text, keyboard)
+}
+
+// Удаляем правило по кнопке и обновляем список
+func (tb *TelegramBot) deleteRule(chatID int64, messageID int, id string) {
+	tb.rules.Remove(chatID, id)
+
+	text, keyboard := tb.renderRules(chatID)
+	tb.editList(chatID, messageID, text, keyboard)
+}
+
+// Проверяем правила чатов, следящих за профилем, по новому снимку.
+// Уведомление отправляется, когда правило начинает выполняться
+func (tb *TelegramBot) checkRules(snapshot *Snapshot) {
+	// Прерванное сканирование исказит стоимость. Снимки с лимитом оцениваемых
+	// предметов проверяем: их можно сравнивать друг с другом
+	if snapshot.Interrupted {
+		return
+	}
+
+	watches := tb.scheduler.List(watchKind, func(task ScheduledTask) bool {
+		payload, ok := taskPayload[WatchPayload](task)
+		return ok && payload.SteamID == snapshot.SteamID && payload.AppID == snapshot.AppID
+	})
+	if len(watches) == 0 {
+		return
+	}
+
+	profile := profileKey(snapshot.SteamID, snapshot.AppID)
+	var history []*Snapshot
+	for _, old := range tb.snapshots.List(snapshot.SteamID, snapshot.AppID) {
+		if !old.Interrupted {
+			history = append(history, old)
+		}
+	}
+	env, items := snapshotEnv(snapshot, history)
+
+	for _, watch := range watches {
+		for _, rule := range tb.rules.List(watch.ChatID) {
+			compiled, err := CompileRule(rule.Text)
+			if err != nil {
+				log.Printf("Ошибка разбора правила %s: %v", rule.ID, err)
+				continue
+			}
+
+			matched, matchedItems := compiled.Evaluate(env, items)
+			if wasActive := tb.rules.SetActive(rule.ID, profile, matched); !matched || wasActive {
+				continue
+			}
+
+			tb.rules.RecordTrigger(rule.ID, Trigger{At: snapshot.TakenAt, Value: snapshot.TotalValue, SteamID: snapshot.SteamID, AppID: snapshot.AppID})
+			tb.sendMessage(watch.ChatID, formatRuleAlert(compiled, snapshot, env, matchedItems))
+		}
+	}
+}
+
+// Уведомление о сработавшем правиле с текстом правила и значениями метрик
+func formatRuleAlert(rule *CompiledRule, snapshot *Snapshot, env ruleEnv, matchedItems []int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "📐 *Сработало правило*\n`%s`\n\n", rule.Text)
+	fmt.Fprintf(&b, "Профиль %s (%s), стоимость %.2f ₽\n", snapshot.SteamID, getGameName(snapshot.AppID), snapshot.TotalValue)
+
+	for _, name := range rule.Metrics {
+		if value, ok := env(name); ok {
+			fmt.Fprintf(&b, "• `%s` = %.2f\n", name, value)
+		}
+	}
+
+	if len(matchedItems) > 0 {
+		fmt.Fprintf(&b, "\n*Подходящие предметы (%d):*\n", len(matchedItems))
+		for i, index := range matchedItems {
+			if i == ruleItemsShown {
+				fmt.Fprintf(&b, "…и еще %d\n", len(matchedItems)-ruleItemsShown)
+				break
+			}
+			item := snapshot.Items[index]
+			fmt.Fprintf(&b, "• %s: %.2f ₽, продано за сутки %d\n", escapeMarkdown(item.Name), item.Price, item.Volume)
+		}
+	}
+
+	if snapshot.Partial {
+		fmt.Fprintf(&b, "\n⚠️ Инвентарь большой: метрики посчитаны по первым %d предметам\n", maxPricedItems)
+	}
+
+	b.WriteString("\nСписок правил: /rules")
+	return b.String()
+}
@@ -0,0 +1,512 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Количество параллельных сканирований
+const scanWorkers = 2
+
+// Сколько предметов инвентаря оцениваем: цены запрашиваются по одной
+const maxPricedItems = 50
+
+// Итоги сканирования без результата (по ним портфель определяет причину)
+const (
+	inventoryUnavailableText = "🔌 Сервис инвентаря Steam сейчас недоступен. Попробуйте позже."
+	scanTimeoutText          = "⏰ Таймаут сканирования. Инвентарь слишком большой или недоступен."
+	emptyInventoryText       = "❌ Инвентарь пуст или недоступен"
+	noMarketableText         = "❌ Нет продаваемых предметов в инвентаре"
+)
+
+// Ставим сканирование в очередь и сообщаем пользователю его позицию.
+// Если этот профиль уже сканируется или ждет в очереди, чат присоединяется к заданию.
+// force сбрасывает кэш и расходует отдельную, более строгую квоту
+func (tb *TelegramBot) enqueueScan(chatID, userID int64, steamID, appID string, force bool) {
+	tb.queueMutex.Lock()
+	notices := tb.pushScan(chatID, userID, steamID, appID, force)
+	tb.queueMutex.Unlock()
+
+	tb.deliver(notices)
+}
+
+// Ставим сканирование в очередь (вызывать под queueMutex).
+// Возвращаем сообщения, которые нужно отправить после освобождения mutex
+func (tb *TelegramBot) pushScan(chatID, userID int64, steamID, appID string, force bool) []jobNotice {
+	key := scanKey(steamID, appID, defaultContextID, marketCurrency)
+
+	// Задание из кэша, которое заменяется полным сканированием
+	var replaced *ScanJob
+	charged := false
+
+	if job, exists := tb.inflight[key]; exists {
+		if !force || !job.Cached {
+			return tb.joinJob(job, chatID)
+		}
+		if !job.running {
+			// Задание еще ждет в очереди заданий из кэша. Полное сканирование должно
+			// идти в общей очереди по кругу между пользователями, поэтому переносим
+			// подписчиков в новое задание
+			if refusal := tb.allowScan(userID, true); refusal != "" {
+				return []jobNotice{{chatID: chatID, text: refusal}}
+			}
+			charged = true
+			if tb.queue.Remove(job) {
+				replaced = job
+			}
+		}
+		// Задание уже отдает данные из кэша - ставим новое
+	}
+
+	job := &ScanJob{
+		UserID:  userID,
+		SteamID: steamID,
+		AppID:   appID,
+		Key:     key,
+		Cached:  !force && tb.cache.Has(key),
+		Force:   force,
+	}
+
+	if !job.Cached && !charged {
+		if refusal := tb.allowScan(userID, force); refusal != "" {
+			return []jobNotice{{chatID: chatID, text: refusal}}
+		}
+	}
+
+	var subscriber *jobSubscriber
+	if replaced != nil {
+		job.subscribers = replaced.subscribers
+		for _, existing := range job.subscribers {
+			if existing.chatID == chatID {
+				subscriber = existing
+			}
+		}
+	}
+	if subscriber == nil {
+		subscriber = &jobSubscriber{chatID: chatID}
+		job.subscribers = append(job.subscribers, subscriber)
+	}
+
+	position, err := tb.queue.Push(job)
+	if err != nil {
+		// Сканирование не состоялось - квоту не расходуем, а замененное задание
+		// возвращаем в очередь
+		if !job.Cached {
+			tb.refundScan(userID, force)
+		}
+		if replaced != nil {
+			if _, err := tb.queue.Push(replaced); err != nil {
+				delete(tb.inflight, key)
+				return replaced.failNotices(queueErrorText(err))
+			}
+		}
+		return []jobNotice{{chatID: chatID, text: queueErrorText(err)}}
+	}
+
+	tb.inflight[key] = job
+
+	// Кэш сбрасываем, только когда задание принято: иначе пользователь потерял бы
+	// и прежний результат
+	if force {
+		tb.cache.Delete(key)
+	}
+
+	if tb.queue.StartsImmediately(position) {
+		return nil
+	}
+
+	var notices []jobNotice
+	for _, subscriber := range job.subscribers {
+		notices = append(notices, tb.statusNotice(subscriber, position)...)
+	}
+	return notices
+}
+
+// Сообщение об ошибке всем подписчикам задания (вызывать под queueMutex)
+func (job *ScanJob) failNotices(text string) []jobNotice {
+	notices := make([]jobNotice, 0, len(job.subscribers))
+	for _, subscriber := range job.subscribers {
+		notices = append(notices, jobNotice{chatID: subscriber.chatID, text: text})
+	}
+	return notices
+}
+
+// Ставим в очередь оценку загруженного инвентаря. Повторная загрузка того же
+// файла, пока он оценивается, присоединяется к заданию
+func (tb *TelegramBot) enqueueImport(chatID, userID int64, imported *InventoryImport) {
+	tb.queueMutex.Lock()
+	notices := tb.pushImport(chatID, userID, imported)
+	tb.queueMutex.Unlock()
+
+	tb.deliver(notices)
+}
+
+// Ставим оценку файла в очередь (вызывать под queueMutex)
+func (tb *TelegramBot) pushImport(chatID, userID int64, imported *InventoryImport) []jobNotice {
+	key := scanKey(imported.ID, imported.AppID, defaultContextID, marketCurrency)
+
+	if job, exists := tb.inflight[key]; exists {
+		return tb.joinJob(job, chatID)
+	}
+
+	// Цены запрашиваются так же, как при сканировании, поэтому и квота общая
+	if ok, wait := tb.scanQuota.Allow(userID); !ok {
+		return []jobNotice{{chatID: chatID, text: "⏳ Лимит сканирований исчерпан. Попробуйте снова через " + formatWait(wait) + "."}}
+	}
+
+	subscriber := &jobSubscriber{chatID: chatID}
+	job := &ScanJob{
+		UserID:      userID,
+		SteamID:     imported.ID,
+		AppID:       imported.AppID,
+		Key:         key,
+		Import:      imported.Inventory,
+		subscribers: []*jobSubscriber{subscriber},
+	}
+
+	position, err := tb.queue.Push(job)
+	if err != nil {
+		tb.scanQuota.Refund(userID)
+		return []jobNotice{{chatID: chatID, text: queueErrorText(err)}}
+	}
+
+	tb.inflight[key] = job
+
+	if tb.queue.StartsImmediately(position) {
+		return nil
+	}
+	return tb.statusNotice(subscriber, position)
+}
+
+// Проверяем, можно ли сейчас сканировать Steam для пользователя, и списываем квоту.
+// Возвращаем текст отказа или пустую строку.
+// Принудительные обновления расходуют отдельную, более строгую квоту
+func (tb *TelegramBot) allowScan(userID int64, force bool) string {
+	// Не заставляем пользователя ждать таймаутов, пока Steam недоступен
+	if !tb.steam.Available(EndpointInventory) {
+		return inventoryUnavailableText
+	}
+
+	quota, limitText := tb.scanQuota, "Лимит сканирований исчерпан"
+	if force {
+		quota, limitText = tb.refreshQuota, "Лимит принудительных обновлений исчерпан"
+	}
+
+	if ok, wait := quota.Allow(userID); !ok {
+		return "⏳ " + limitText + ". Попробуйте снова через " + formatWait(wait) + "."
+	}
+
+	return ""
+}
+
+// Возвращаем квоту, списанную allowScan
+func (tb *TelegramBot) refundScan(userID int64, force bool) {
+	if force {
+		tb.refreshQuota.Refund(userID)
+	} else {
+		tb.scanQuota.Refund(userID)
+	}
+}
+
+// Почему очередь не приняла задание
+func queueErrorText(err error) string {
+	if errors.Is(err, ErrUserQueueFull) {
+		return "🚦 У вас уже есть сканирования в очереди. Дождитесь их завершения."
+	}
+	return "🚦 Очередь сканирований переполнена. Попробуйте через несколько минут."
+}
+
+// Ставим в очередь фоновое обновление устаревшего результата. Такое задание
+// не попадает в inflight: пока оно выполняется, пользователи получают данные из кэша
+func (tb *TelegramBot) enqueueRefresh(steamID, appID string) {
+	job := &ScanJob{
+		SteamID: steamID,
+		AppID:   appID,
+		Key:     scanKey(steamID, appID, defaultContextID, marketCurrency),
+		Refresh: true,
+	}
+
+	if _, err := tb.queue.Push(job); err != nil {
+		tb.cache.EndRefresh(job.Key)
+	}
+}
+
+// Ставим в очередь плановое пересканирование наблюдаемого профиля. Задание
+// попадает в inflight, поэтому ручные запросы того же профиля присоединяются к нему.
+// Возвращает false, если очередь не приняла задание и его нужно повторить позже
+func (tb *TelegramBot) enqueueScheduled(userID int64, steamID, appID string) bool {
+	key := scanKey(steamID, appID, defaultContextID, marketCurrency)
+
+	tb.queueMutex.Lock()
+	defer tb.queueMutex.Unlock()
+
+	// Профиль уже сканируется: его снимок тоже проверяется наблюдением
+	if job, exists := tb.inflight[key]; exists && !job.Cached {
+		return true
+	}
+
+	job := &ScanJob{
+		UserID:    userID,
+		SteamID:   steamID,
+		AppID:     appID,
+		Key:       key,
+		Scheduled: true,
+	}
+
+	if _, err := tb.queue.Push(job); err != nil {
+		return false
+	}
+
+	tb.inflight[key] = job
+	return true
+}
+
+// Ставим в очередь сканирование аккаунта для портфеля. Если профиль уже
+// сканируется, возвращаем это задание: его результат подойдет и портфелю
+func (tb *TelegramBot) enqueuePortfolio(userID int64, steamID, appID string, prices map[string]MarketPrice) (*ScanJob, error) {
+	key := scanKey(steamID, appID, defaultContextID, marketCurrency)
+
+	tb.queueMutex.Lock()
+	defer tb.queueMutex.Unlock()
+
+	if job, exists := tb.inflight[key]; exists && !job.Cached {
+		return job, nil
+	}
+
+	job := &ScanJob{
+		UserID:    userID,
+		SteamID:   steamID,
+		AppID:     appID,
+		Key:       key,
+		Portfolio: true,
+		Prices:    prices,
+	}
+
+	if _, err := tb.queue.Push(job); err != nil {
+		return nil, err
+	}
+
+	tb.inflight[key] = job
+	return job, nil
+}
+
+// Канал, который закроется по завершении задания
+func (tb *TelegramBot) jobDone(job *ScanJob) <-chan struct{} {
+	tb.queueMutex.Lock()
+	defer tb.queueMutex.Unlock()
+
+	if job.done == nil {
+		job.done = make(chan struct{})
+		if job.finished {
+			close(job.done)
+		}
+	}
+	return job.done
+}
+
+// Отмечаем задание выполненным и будим тех, кто его ждет
+func (tb *TelegramBot) completeJob(job *ScanJob) {
+	tb.queueMutex.Lock()
+	defer tb.queueMutex.Unlock()
+
+	job.finished = true
+	if job.done != nil {
+		close(job.done)
+	}
+}
+
+// Присоединяем чат к уже существующему заданию (вызывать под queueMutex)
+func (tb *TelegramBot) joinJob(job *ScanJob, chatID int64) []jobNotice {
+	for _, subscriber := range job.subscribers {
+		if subscriber.chatID == chatID {
+			return []jobNotice{{chatID: chatID, text: "⏳ Этот инвентарь уже сканируется, результат придет сюда."}}
+		}
+	}
+
+	subscriber := &jobSubscriber{chatID: chatID}
+	job.subscribers = append(job.subscribers, subscriber)
+
+	if job.running {
+		return []jobNotice{{chatID: chatID, text: "⏳ Этот инвентарь уже сканируется по другому запросу. Присоединяю вас к нему."}}
+	}
+
+	for i, queued := range tb.queue.Jobs() {
+		if queued == job {
+			return tb.statusNotice(subscriber, i+1)
+		}
+	}
+	return nil
+}
+
+// Воркер: берет задания из очереди по одному
+func (tb *TelegramBot) scanWorker() {
+	for {
+		job, err := tb.queue.Pop(context.Background())
+		if err != nil {
+			return
+		}
+
+		tb.startJob(job)
+
+		started := time.Now()
+		tb.scanInventory(job)
+		tb.closeJob(job)
+		tb.completeJob(job)
+		if job.Refresh {
+			// Если обновление не удалось, следующий запрос попробует снова
+			tb.cache.EndRefresh(job.Key)
+		}
+		tb.queue.Done(job, time.Since(started))
+	}
+}
+
+// Задание пошло в работу: убираем его сообщения о позиции и сдвигаем остальных
+func (tb *TelegramBot) startJob(job *ScanJob) {
+	var notices []jobNotice
+
+	tb.queueMutex.Lock()
+	job.running = true
+	for _, subscriber := range job.subscribers {
+		subscriber.started = true
+		if subscriber.statusMessageID != 0 {
+			notices = append(notices, jobNotice{chatID: subscriber.chatID, messageID: subscriber.statusMessageID, text: jobStartedText})
+		}
+	}
+
+	for i, queued := range tb.queue.Jobs() {
+		for _, subscriber := range queued.subscribers {
+			notices = append(notices, tb.statusNotice(subscriber, i+1)...)
+		}
+	}
+	tb.queueMutex.Unlock()
+
+	tb.deliver(notices)
+}
+
+// Закрываем задание: новые запросы больше не присоединяются к нему.
+// Возвращаем чаты, которым нужно отправить результат
+func (tb *TelegramBot) closeJob(job *ScanJob) []int64 {
+	tb.queueMutex.Lock()
+	defer tb.queueMutex.Unlock()
+
+	if tb.inflight[job.Key] == job {
+		delete(tb.inflight, job.Key)
+	}
+
+	return job.chatIDs()
+}
+
+// Завершаем задание одним итоговым сообщением для всех подписчиков
+func (tb *TelegramBot) finishJob(job *ScanJob, text string) {
+	job.failure = text
+	for _, chatID := range tb.closeJob(job) {
+		tb.sendMessage(chatID, text)
+	}
+}
+
+// Отправляем сообщение о прогрессе всем подписчикам задания
+func (tb *TelegramBot) broadcast(job *ScanJob, text string) {
+	tb.queueMutex.Lock()
+	chats := job.chatIDs()
+	tb.queueMutex.Unlock()
+
+	for _, chatID := range chats {
+		tb.sendMessage(chatID, text)
+	}
+}
+
+// Чаты подписчиков (вызывать под queueMutex)
+func (job *ScanJob) chatIDs() []int64 {
+	chats := make([]int64, 0, len(job.subscribers))
+	for _, subscriber := range job.subscribers {
+		chats = append(chats, subscriber.chatID)
+	}
+	return chats
+}
+
+const jobStartedText = "▶️ Ваша очередь подошла"
+
+// Сообщение подписчику. Собирается под queueMutex, а отправляется после его
+// освобождения, чтобы медленный Telegram не задерживал очередь и обработку обновлений
+type jobNotice struct {
+	chatID    int64
+	messageID int // не 0 - редактируем это сообщение
+	text      string
+
+	// Новое сообщение о позиции: его ID запоминаем у подписчика
+	subscriber *jobSubscriber
+	position   int
+}
+
+// Сообщение о позиции в очереди: новое или правка прежнего (вызывать под queueMutex)
+func (tb *TelegramBot) statusNotice(subscriber *jobSubscriber, position int) []jobNotice {
+	if position == subscriber.lastPosition {
+		return nil
+	}
+	subscriber.lastPosition = position
+
+	// Первое сообщение еще отправляется: актуальную позицию допишем, когда узнаем его ID
+	if subscriber.sending {
+		return nil
+	}
+
+	text := tb.positionText(position)
+	if subscriber.statusMessageID != 0 {
+		return []jobNotice{{chatID: subscriber.chatID, messageID: subscriber.statusMessageID, text: text}}
+	}
+
+	subscriber.sending = true
+	return []jobNotice{{chatID: subscriber.chatID, text: text, subscriber: subscriber, position: position}}
+}
+
+func (tb *TelegramBot) positionText(position int) string {
+	return fmt.Sprintf("🕐 Вы #%d в очереди, ~%s", position, formatWait(tb.queue.Estimate(position)))
+}
+
+// Отправляем собранные сообщения (без queueMutex)
+func (tb *TelegramBot) deliver(notices []jobNotice) {
+	for _, notice := range notices {
+		switch {
+		case notice.messageID != 0:
+			tb.editMessage(notice.chatID, notice.messageID, notice.text)
+		case notice.subscriber != nil:
+			tb.sendStatus(notice)
+		default:
+			tb.sendMessage(notice.chatID, notice.text)
+		}
+	}
+}
+
+// Отправляем первое сообщение о позиции и запоминаем его ID. Если за время
+// отправки задание сдвинулось или началось, сразу правим сообщение
+func (tb *TelegramBot) sendStatus(notice jobNotice) {
+	sent, err := tb.bot.Send(tgbotapi.NewMessage(notice.chatID, notice.text))
+
+	tb.queueMutex.Lock()
+	subscriber := notice.subscriber
+	subscriber.sending = false
+	if err == nil {
+		subscriber.statusMessageID = sent.MessageID
+	}
+	started, position := subscriber.started, subscriber.lastPosition
+	tb.queueMutex.Unlock()
+
+	switch {
+	case err != nil:
+		log.Printf("Ошибка отправки сообщения: %v", err)
+	case started:
+		tb.editMessage(notice.chatID, sent.MessageID, jobStartedText)
+	case position != notice.position:
+		tb.editMessage(notice.chatID, sent.MessageID, tb.positionText(position))
+	}
+}
+
+// Ключ сканирования: по нему объединяются одновременные запросы и хранится кэш
+func scanKey(steamID, appID, contextID string, currency int) string {
+	return fmt.Sprintf("%s_%s_%s_%d", steamID, appID, contextID, currency)
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"sort"
+	"time"
+)
+
+// Как часто планировщик проверяет задачи
+const schedulerTick = 30 * time.Second
+
+// Задача планировщика. Kind выбирает обработчик, Payload - его данные
+type ScheduledTask struct {
+	ID       string          `json:"id"`
+	Kind     string          `json:"kind"`
+	ChatID   int64           `json:"chat_id"`
+	UserID   int64           `json:"user_id"`
+	Interval time.Duration   `json:"interval"` // для периодических задач
+	NextRun  time.Time       `json:"next_run"`
+	Payload  json.RawMessage `json:"payload"`
+}
+
+// Данные задачи нужного типа
+func taskPayload[T any](task ScheduledTask) (T, bool) {
+	var payload T
+	if err := json.Unmarshal(task.Payload, &payload); err != nil {
+		log.Printf("Ошибка разбора задачи %s: %v", task.ID, err)
+		return payload, false
+	}
+	return payload, true
+}
+
+// Задача с данными payload
+func newTask(id, kind string, chatID, userID int64, payload any) ScheduledTask {
+	data, _ := json.Marshal(payload)
+	return ScheduledTask{ID: id, Kind: kind, ChatID: chatID, UserID: userID, Payload: data}
+}
+
+// Заменяем данные задачи
+func (t *ScheduledTask) setPayload(payload any) {
+	t.Payload, _ = json.Marshal(payload)
+}
+
+// Обработчик задачи. Возвращает время следующего запуска; нулевое время удаляет задачу
+type TaskHandler func(task ScheduledTask) time.Time
+
+// Планировщик периодических задач (наблюдение за профилями, сводки, живые сообщения).
+// Задачи переживают перезапуск: они сохраняются в то же хранилище, что и кэш
+type Scheduler struct {
+	tasks    *Store[ScheduledTask]
+	handlers map[string]TaskHandler
+	done     chan struct{}
+}
+
+func NewScheduler(backend CacheBackend) *Scheduler {
+	return &Scheduler{
+		tasks:    NewStore[ScheduledTask]("задач планировщика", backend),
+		handlers: make(map[string]TaskHandler),
+		done:     make(chan struct{}),
+	}
+}
+
+// Регистрируем обработчик задач вида kind (до Start)
+func (s *Scheduler) Handle(kind string, handler TaskHandler) {
+	s.handlers[kind] = handler
+}
+
+// Запускаем проверку задач в фоне
+func (s *Scheduler) Start() {
+	go s.loop()
+}
+
+func (s *Scheduler) loop() {
+	ticker := time.NewTicker(schedulerTick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+		}
+
+		s.runDue(time.Now())
+	}
+}
+
+// Запускаем наступившие задачи по порядку времени запуска
+func (s *Scheduler) runDue(now time.Time) {
+	due := s.tasks.Values(func(task ScheduledTask) bool {
+		return !task.NextRun.After(now)
+	})
+
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].NextRun.Before(due[j].NextRun)
+	})
+
+	for _, task := range due {
+		handler, exists := s.handlers[task.Kind]
+		if !exists {
+			log.Printf("Нет обработчика для задачи %s (%s)", task.ID, task.Kind)
+			continue
+		}
+
+		next := handler(task)
+
+		// Пока обработчик работал, задачу могли удалить или изменить
+		s.Update(task.ID, func(current *ScheduledTask) bool {
+			if next.IsZero() {
+				return false
+			}
+			current.NextRun = next
+			return true
+		})
+	}
+}
+
+// Добавляем или заменяем задачу
+func (s *Scheduler) Add(task ScheduledTask) {
+	s.tasks.Set(task.ID, task)
+}
+
+// Изменяем задачу. update возвращает false, если задачу нужно удалить.
+// Возвращает false, если задачи нет
+func (s *Scheduler) Update(id string, update func(task *ScheduledTask) bool) bool {
+	found := false
+	s.tasks.Update(id, func(task *ScheduledTask, exists bool) storeAction {
+		if found = exists; !exists {
+			return storeKeep
+		}
+		if update(task) {
+			return storeSave
+		}
+		return storeDelete
+	})
+	return found
+}
+
+// Удаляем задачу. Возвращает false, если задачи не было
+func (s *Scheduler) Remove(id string) bool {
+	return s.tasks.Delete(id)
+}
+
+// Задача по идентификатору
+func (s *Scheduler) Get(id string) (ScheduledTask, bool) {
+	return s.tasks.Get(id)
+}
+
+// Задачи вида kind, подходящие под условие (match может быть nil), по идентификатору
+func (s *Scheduler) List(kind string, match func(task ScheduledTask) bool) []ScheduledTask {
+	tasks := s.tasks.Values(func(task ScheduledTask) bool {
+		return task.Kind == kind && (match == nil || match(task))
+	})
+
+	sort.Slice(tasks, func(i, j int) bool {
+		return tasks[i].ID < tasks[j].ID
+	})
+	return tasks
+}
+
+// Останавливаем планировщик
+func (s *Scheduler) Close() {
+	close(s.done)
+	s.tasks.Close()
+}
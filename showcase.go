@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/jpeg"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	xdraw "golang.org/x/image/draw"
+)
+
+// Сетка витрины: самые дорогие предметы по showcaseColumns в ряд
+const (
+	showcaseItems   = 12
+	showcaseColumns = 4
+	showcaseCellW   = 200
+	showcaseCellH   = 220
+	showcaseGap     = 16
+	showcaseHeader  = 96
+	showcaseBorder  = 4
+)
+
+// Цвет рамки предмета без name_color
+var colorDefaultRarity = color.RGBA{0xb0, 0xc3, 0xd9, 0xff}
+
+// Дисковый кэш иконок предметов: иконки не меняются, поэтому хранятся без срока
+type IconStore struct {
+	dir    string
+	client *http.Client
+}
+
+// Каталог иконок: ICON_CACHE_DIR, иначе рядом с постоянным кэшем, иначе во временном каталоге
+func iconCacheDir() string {
+	if dir := os.Getenv("ICON_CACHE_DIR"); dir != "" {
+		return dir
+	}
+	if dir := os.Getenv("CACHE_DIR"); dir != "" {
+		return filepath.Join(dir, "icons")
+	}
+	return filepath.Join(os.TempDir(), "steam-icons")
+}
+
+func NewIconStore(dir string) *IconStore {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		log.Printf("Каталог иконок недоступен, иконки не кэшируются: %v", err)
+	}
+
+	return &IconStore{
+		dir:    dir,
+		client: &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+// Иконка предмета по icon_url из описания Steam
+func (s *IconStore) Get(ctx context.Context, icon string) (image.Image, error) {
+	sum := sha256.Sum256([]byte(icon))
+	path := filepath.Join(s.dir, hex.EncodeToString(sum[:])+".img")
+
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		if err := s.download(ctx, icon, path); err != nil {
+			return nil, err
+		}
+		file, err = os.Open(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	img, _, err := image.Decode(file)
+	if err != nil {
+		// Поврежденный файл удаляем, чтобы в следующий раз скачать заново
+		os.Remove(path)
+	}
+	return img, err
+}
+
+// Скачиваем иконку с CDN Steam во временный файл и переименовываем
+func (s *IconStore) download(ctx context.Context, icon, path string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, steamImageURL+icon+"/256fx192f", nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("icon status: %s", resp.Status)
+	}
+
+	tmp, err := os.CreateTemp(s.dir, "icon-*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(tmp, io.LimitReader(resp.Body, 2<<20)); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// Рисуем витрину: сетка самых дорогих предметов с ценами и общая стоимость.
+// icon возвращает картинку предмета или nil, если ее нет
+func RenderShowcase(result *ScanResult, icon func(InventoryItem) image.Image) ([]byte, error) {
+	rows := groupItems(result.Items, sortByPrice)
+	if len(rows) > showcaseItems {
+		rows = rows[:showcaseItems]
+	}
+	if len(rows) == 0 {
+		return nil, errors.New("нет предметов с ценой")
+	}
+
+	gridRows := (len(rows) + showcaseColumns - 1) / showcaseColumns
+	width := showcaseColumns*showcaseCellW + (showcaseColumns+1)*showcaseGap
+	height := showcaseHeader + gridRows*showcaseCellH + (gridRows+1)*showcaseGap
+
+	canvas := image.NewRGBA(image.Rect(0, 0, width, height))
+	fillRect(canvas, canvas.Bounds(), colorBackground)
+
+	titleFace := newFace(true, 28)
+	subtitleFace := newFace(false, 18)
+	nameFace := newFace(false, 15)
+	priceFace := newFace(true, 17)
+
+	drawText(canvas, titleFace, showcaseGap, 42, fitText(titleFace, "Инвентарь "+result.SteamID, width-2*showcaseGap), colorText)
+	subtitle := fmt.Sprintf("%s · Общая стоимость: %s", getGameName(result.AppID), formatRubles(BuildReport(result).TotalValue))
+	drawText(canvas, subtitleFace, showcaseGap, 76, subtitle, colorAccent)
+
+	for i, row := range rows {
+		x := showcaseGap + (i%showcaseColumns)*(showcaseCellW+showcaseGap)
+		y := showcaseHeader + showcaseGap + (i/showcaseColumns)*(showcaseCellH+showcaseGap)
+		cell := image.Rect(x, y, x+showcaseCellW, y+showcaseCellH)
+
+		fillRect(canvas, cell, colorPanel)
+		strokeRect(canvas, cell, showcaseBorder, parseHexColor(row.Item.NameColor, colorDefaultRarity))
+
+		// Иконка вписывается в область над подписью с сохранением пропорций
+		iconArea := image.Rect(x+12, y+12, x+showcaseCellW-12, y+showcaseCellH-66)
+		if img := icon(row.Item); img != nil {
+			xdraw.CatmullRom.Scale(canvas, fitRect(img.Bounds(), iconArea), img, img.Bounds(), xdraw.Over, nil)
+		}
+
+		textWidthLimit := showcaseCellW - 2*12
+		drawText(canvas, nameFace, x+12, y+showcaseCellH-40, fitText(nameFace, row.Item.Name, textWidthLimit), colorText)
+
+		price := formatRubles(row.Item.PriceValue)
+		if row.Quantity > 1 {
+			price = fmt.Sprintf("%s ×%d", price, row.Quantity)
+		}
+		drawText(canvas, priceFace, x+12, y+showcaseCellH-16, fitText(priceFace, price, textWidthLimit), colorPrice)
+	}
+
+	return encodePNG(canvas)
+}
+
+// Прямоугольник размера src, вписанный по центру area
+func fitRect(src, area image.Rectangle) image.Rectangle {
+	scale := float64(area.Dx()) / float64(src.Dx())
+	if s := float64(area.Dy()) / float64(src.Dy()); s < scale {
+		scale = s
+	}
+
+	w, h := int(float64(src.Dx())*scale), int(float64(src.Dy())*scale)
+	x := area.Min.X + (area.Dx()-w)/2
+	y := area.Min.Y + (area.Dy()-h)/2
+	return image.Rect(x, y, x+w, y+h)
+}
+
+// Рисуем и отправляем витрину инвентаря (кнопка под отчетом)
+func (tb *TelegramBot) sendShowcase(chatID int64, steamID, appID string) {
+	result, ok := tb.scanResult(chatID, steamID, appID)
+	if !ok {
+		return
+	}
+
+	tb.sendMessage(chatID, "🖼 Рисую витрину...")
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	data, err := RenderShowcase(result, func(item InventoryItem) image.Image {
+		if item.IconURL == "" {
+			return nil
+		}
+		img, err := tb.icons.Get(ctx, item.IconURL)
+		if err != nil {
+			log.Printf("Ошибка загрузки иконки: %v", err)
+			return nil
+		}
+		return img
+	})
+	if err != nil {
+		log.Printf("Ошибка рисования витрины: %v", err)
+		tb.sendMessage(chatID, "❌ Не удалось нарисовать витрину")
+		return
+	}
+
+	photo := tgbotapi.NewPhoto(chatID, tgbotapi.FileBytes{Name: "showcase.png", Bytes: data})
+	photo.Caption = fmt.Sprintf("🖼 Витрина инвентаря %s (%s)", result.SteamID, getGameName(result.AppID))
+
+	if _, err := tb.bot.Send(photo); err != nil {
+		log.Printf("Ошибка отправки фото: %v", err)
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Сколько храним снимки и сколько снимков одного профиля держим максимум
+const (
+	snapshotRetention   = 365 * 24 * time.Hour
+	maxProfileSnapshots = 500
+)
+
+// Снимок инвентаря после завершенного сканирования
+type Snapshot struct {
+	SteamID    string    `json:"steam_id"`
+	AppID      string    `json:"app_id"`
+	TakenAt    time.Time `json:"taken_at"`
+	TotalValue float64   `json:"total_value"`
+	Partial    bool      `json:"partial"`
+	// Загрузка или оценка прервалась. Снимок с лимитом оцениваемых предметов
+	// тоже неполный, но его можно сравнивать с такими же снимками
+	Interrupted bool           `json:"interrupted,omitempty"`
+	Items       []SnapshotItem `json:"items"`
+}
+
+// Предмет снимка: только то, что нужно для истории и сравнения
+type SnapshotItem struct {
+	AssetID        string  `json:"asset_id"`
+	Name           string  `json:"name"`
+	MarketHashName string  `json:"market_hash_name"`
+	Quantity       int     `json:"quantity"`
+	Price          float64 `json:"price"`
+	Volume         int     `json:"volume,omitempty"` // продано на площадке за сутки
+}
+
+// Стоимость всей стопки
+func (item SnapshotItem) Value() float64 {
+	return item.Price * float64(item.Quantity)
+}
+
+// Снимок из результата сканирования
+func NewSnapshot(result *ScanResult) *Snapshot {
+	snapshot := &Snapshot{
+		SteamID:     result.SteamID,
+		AppID:       result.AppID,
+		TakenAt:     result.ScannedAt,
+		Partial:     result.Partial(),
+		Interrupted: result.FetchPartial || result.PricingPartial,
+		Items:       make([]SnapshotItem, 0, len(result.Items)),
+	}
+
+	for _, item := range result.Items {
+		snapshot.Items = append(snapshot.Items, SnapshotItem{
+			AssetID:        item.AssetID,
+			Name:           item.Name,
+			MarketHashName: item.MarketHashName,
+			Quantity:       item.Quantity(),
+			Price:          item.PriceValue,
+			Volume:         item.Volume,
+		})
+		snapshot.TotalValue += item.Value()
+	}
+
+	return snapshot
+}
+
+// Ключ снимка в хранилище
+func (s *Snapshot) key() string {
+	return fmt.Sprintf("%s_%s_%d", s.SteamID, s.AppID, s.TakenAt.UnixNano())
+}
+
+// Хранилище снимков: индекс в памяти, копия в постоянном хранилище
+// (тот же CACHE_BACKEND, что и у кэша, отдельное пространство имен)
+type SnapshotStore struct {
+	mutex    sync.Mutex
+	profiles map[string][]*Snapshot // steamID_appID -> снимки по возрастанию времени
+	backend  CacheBackend
+}
+
+func NewSnapshotStore(backend CacheBackend) *SnapshotStore {
+	store := &SnapshotStore{
+		profiles: make(map[string][]*Snapshot),
+		backend:  backend,
+	}
+
+	if backend != nil {
+		store.restore()
+	}
+
+	return store
+}
+
+func profileKey(steamID, appID string) string {
+	return steamID + "_" + appID
+}
+
+// Загружаем сохраненные снимки
+func (s *SnapshotStore) restore() {
+	stored, err := s.backend.Load()
+	if err != nil {
+		log.Printf("Ошибка загрузки снимков: %v", err)
+	}
+
+	for _, record := range stored {
+		var snapshot Snapshot
+		if err := json.Unmarshal(record.Value, &snapshot); err != nil {
+			continue
+		}
+		key := profileKey(snapshot.SteamID, snapshot.AppID)
+		s.profiles[key] = append(s.profiles[key], &snapshot)
+	}
+
+	for _, snapshots := range s.profiles {
+		sort.Slice(snapshots, func(i, j int) bool {
+			return snapshots[i].TakenAt.Before(snapshots[j].TakenAt)
+		})
+	}
+
+	if len(stored) > 0 {
+		log.Printf("Восстановлено снимков инвентарей: %d", len(stored))
+	}
+}
+
+// Сохраняем снимок; самые старые снимки профиля сверх лимита удаляются
+func (s *SnapshotStore) Add(snapshot *Snapshot) {
+	key := profileKey(snapshot.SteamID, snapshot.AppID)
+
+	s.mutex.Lock()
+	snapshots := append(s.profiles[key], snapshot)
+	var dropped []*Snapshot
+	if len(snapshots) > maxProfileSnapshots {
+		dropped = snapshots[:len(snapshots)-maxProfileSnapshots]
+		snapshots = append([]*Snapshot(nil), snapshots[len(snapshots)-maxProfileSnapshots:]...)
+	}
+	s.profiles[key] = snapshots
+	s.mutex.Unlock()
+
+	if s.backend == nil {
+		return
+	}
+
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		log.Printf("Ошибка сериализации снимка: %v", err)
+		return
+	}
+
+	err = s.backend.Save(StoredEntry{
+		Key:         snapshot.key(),
+		Value:       data,
+		CreatedAt:   snapshot.TakenAt,
+		ExpiresAt:   snapshot.TakenAt.Add(snapshotRetention),
+		DeleteAfter: snapshot.TakenAt.Add(snapshotRetention),
+	})
+	if err != nil {
+		log.Printf("Ошибка сохранения снимка: %v", err)
+	}
+
+	for _, old := range dropped {
+		if err := s.backend.Delete(old.key()); err != nil {
+			log.Printf("Ошибка удаления снимка: %v", err)
+		}
+	}
+}
+
+// Снимки профиля по возрастанию времени (без снимков старше срока хранения)
+func (s *SnapshotStore) List(steamID, appID string) []*Snapshot {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	cutoff := time.Now().Add(-snapshotRetention)
+	var snapshots []*Snapshot
+	for _, snapshot := range s.profiles[profileKey(steamID, appID)] {
+		if snapshot.TakenAt.After(cutoff) {
+			snapshots = append(snapshots, snapshot)
+		}
+	}
+	return snapshots
+}
+
+// Последний снимок профиля
+func (s *SnapshotStore) Latest(steamID, appID string) (*Snapshot, bool) {
+	snapshots := s.List(steamID, appID)
+	if len(snapshots) == 0 {
+		return nil, false
+	}
+	return snapshots[len(snapshots)-1], true
+}
+
+func (s *SnapshotStore) Close() {
+	if s.backend == nil {
+		return
+	}
+	if err := s.backend.Close(); err != nil {
+		log.Printf("Ошибка закрытия хранилища снимков: %v", err)
+	}
+}
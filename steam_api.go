@@ -1,11 +1,13 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -30,19 +32,37 @@ type Asset struct {
 }
 
 type Description struct {
-	AppID                     int    `json:"appid"`
-	ClassID                   string `json:"classid"`
-	InstanceID                string `json:"instanceid"`
-	IconURL                   string `json:"icon_url"`
-	IconURLLarge              string `json:"icon_url_large"`
-	Name                      string `json:"name"`
-	MarketName                string `json:"market_name"`
-	MarketHashName            string `json:"market_hash_name"`
-	Type                      string `json:"type"`
-	Tradable                  int    `json:"tradable"`
-	Marketable                int    `json:"marketable"`
-	Commodity                 int    `json:"commodity"`
-	MarketTradableRestriction int    `json:"market_tradable_restriction"`
+	AppID                     int      `json:"appid"`
+	ClassID                   string   `json:"classid"`
+	InstanceID                string   `json:"instanceid"`
+	IconURL                   string   `json:"icon_url"`
+	IconURLLarge              string   `json:"icon_url_large"`
+	Name                      string   `json:"name"`
+	MarketName                string   `json:"market_name"`
+	MarketHashName            string   `json:"market_hash_name"`
+	Type                      string   `json:"type"`
+	Tradable                  int      `json:"tradable"`
+	Marketable                int      `json:"marketable"`
+	Commodity                 int      `json:"commodity"`
+	MarketTradableRestriction int      `json:"market_tradable_restriction"`
+	Tags                      []Tag    `json:"tags"`
+	Actions                   []Action `json:"actions"`
+	NameColor                 string   `json:"name_color"` // цвет редкости, например "eb4b4b"
+}
+
+// Действие предмета, например ссылка "Inspect in Game..." с подстановками
+// %owner_steamid% и %assetid%
+type Action struct {
+	Link string `json:"link"`
+	Name string `json:"name"`
+}
+
+// Тег предмета: категория (Type, Rarity, Exterior, ItemSet...) и значение
+type Tag struct {
+	Category              string `json:"category"`
+	InternalName          string `json:"internal_name"`
+	LocalizedCategoryName string `json:"localized_category_name"`
+	LocalizedTagName      string `json:"localized_tag_name"`
 }
 
 type MarketPriceResponse struct {
@@ -52,13 +72,177 @@ type MarketPriceResponse struct {
 	Volume      string `json:"volume"`
 }
 
-func fetchAllInventory(steamID, appID, contextID string, debug bool) ([]Asset, []Description, int) {
+// Эндпоинты Steam, для каждого из которых ведется отдельный лимит запросов
+type Endpoint string
+
+const (
+	EndpointInventory Endpoint = "inventory"
+	EndpointMarket    Endpoint = "market"
+	EndpointProfile   Endpoint = "profile"
+)
+
+const userAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36"
+
+const (
+	defaultContextID = "2" // контекст предметов игр в инвентаре Steam
+	marketCurrency   = 5   // валюта цен торговой площадки (рубли)
+)
+
+// Эндпоинты в порядке вывода в /status
+var steamEndpoints = []Endpoint{EndpointInventory, EndpointMarket, EndpointProfile}
+
+// Клиент Steam: все запросы проходят через общий rate limiter,
+// скорость которого снижается при ответах 429, и через circuit breaker эндпоинта.
+// Цены и Steam ID профилей кэшируются и общие для всех сканирований
+type SteamClient struct {
+	httpClient *http.Client
+	limiter    *RateLimiter
+	throttle   *AdaptiveThrottle
+	breakers   map[Endpoint]*CircuitBreaker
+	stats      map[Endpoint]*EndpointStats
+	prices     *Cache[MarketPrice]
+	profiles   *Cache[string]
+}
+
+func NewSteamClient(limiter *RateLimiter) *SteamClient {
+	sc := &SteamClient{
+		httpClient: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+		limiter:  limiter,
+		throttle: NewAdaptiveThrottle(limiter),
+		breakers: make(map[Endpoint]*CircuitBreaker),
+		stats:    make(map[Endpoint]*EndpointStats),
+		prices: NewCache(CacheOptions[MarketPrice]{
+			TTL:        15 * time.Minute,
+			MaxEntries: 20000,
+		}),
+		profiles: NewCache(CacheOptions[string]{
+			TTL:        24 * time.Hour,
+			MaxEntries: 10000,
+		}),
+	}
+
+	for _, endpoint := range steamEndpoints {
+		sc.breakers[endpoint] = NewCircuitBreaker(5, time.Minute)
+		sc.stats[endpoint] = NewEndpointStats()
+	}
+
+	return sc
+}
+
+// Сколько раз повторяем запрос, получивший 429
+const maxThrottleRetries = 3
+
+// Выполняем GET запрос к эндпоинту Steam, дождавшись разрешения лимитера
+func (sc *SteamClient) get(ctx context.Context, endpoint Endpoint, rawURL string) (*http.Response, error) {
+	breaker := sc.breakers[endpoint]
+	stats := sc.stats[endpoint]
+
+	for attempt := 0; ; attempt++ {
+		if err := breaker.Allow(); err != nil {
+			return nil, circuitOpenError(endpoint)
+		}
+
+		if err := sc.limiter.Wait(ctx, endpoint); err != nil {
+			breaker.Release()
+			return nil, err
+		}
+
+		req, err := http.NewRequestWithContext(ctx, "GET", rawURL, nil)
+		if err != nil {
+			breaker.Release()
+			return nil, err
+		}
+
+		req.Header.Set("User-Agent", userAgent)
+
+		started := time.Now()
+		resp, err := sc.httpClient.Do(req)
+		latency := time.Since(started)
+
+		if err != nil {
+			// Отмена запроса вызывающим кодом не говорит о здоровье Steam
+			if ctx.Err() != nil {
+				breaker.Release()
+				return nil, err
+			}
+			stats.Record(latency, true)
+			breaker.OnFailure()
+			return nil, err
+		}
+
+		if resp.StatusCode >= 500 {
+			stats.Record(latency, true)
+			breaker.OnFailure()
+			return resp, nil
+		}
+
+		breaker.OnSuccess()
+
+		if resp.StatusCode != http.StatusTooManyRequests {
+			stats.Record(latency, false)
+			sc.throttle.OnSuccess(endpoint)
+			return resp, nil
+		}
+
+		// Steam ограничивает запросы: замедляем всех и повторяем через лимитер
+		stats.Record(latency, true)
+		sc.throttle.OnThrottled(endpoint)
+		if attempt >= maxThrottleRetries {
+			return resp, nil
+		}
+		resp.Body.Close()
+	}
+}
+
+// Останавливаем фоновую очистку кэшей клиента
+func (sc *SteamClient) Close() {
+	sc.prices.Close()
+	sc.profiles.Close()
+}
+
+// Доступен ли эндпоинт (breaker не в состоянии "недоступен")
+func (sc *SteamClient) Available(endpoint Endpoint) bool {
+	return sc.breakers[endpoint].State() != BreakerOpen
+}
+
+// Состояние эндпоинта для /status
+type EndpointStatus struct {
+	Endpoint  Endpoint
+	State     BreakerState
+	RateShare float64
+	Stats     StatsSummary
+}
+
+func (sc *SteamClient) Status() []EndpointStatus {
+	var statuses []EndpointStatus
+	for _, endpoint := range steamEndpoints {
+		statuses = append(statuses, EndpointStatus{
+			Endpoint:  endpoint,
+			State:     sc.breakers[endpoint].State(),
+			RateShare: sc.throttle.Share(endpoint),
+			Stats:     sc.stats[endpoint].Summary(),
+		})
+	}
+	return statuses
+}
+
+// Снижена ли сейчас скорость запросов из-за ответов 429
+func (sc *SteamClient) Throttled() bool {
+	return sc.throttle.Throttled()
+}
+
+// Загружаем все страницы инвентаря. Ошибка возвращается, если загрузка прервалась
+// из-за сбоя запроса; уже полученные страницы при этом тоже возвращаются
+func (sc *SteamClient) fetchAllInventory(ctx context.Context, steamID, appID, contextID string, debug bool) ([]Asset, []Description, int, error) {
 	allAssets := []Asset{}
 	allDescriptions := []Description{}
 	descMap := make(map[string]Description)
 	totalCount := 0
 	startAssetID := ""
 	page := 0
+	var fetchErr error
 
 	for {
 		page++
@@ -71,14 +255,12 @@ func fetchAllInventory(steamID, appID, contextID string, debug bool) ([]Asset, [
 			fmt.Printf("[DEBUG] Fetching page %d (start_assetid=%s)\n", page, startAssetID)
 		}
 
-		client := &http.Client{
-			Timeout: 30 * time.Second,
-		}
-		resp, err := client.Get(apiURL)
+		resp, err := sc.get(ctx, EndpointInventory, apiURL)
 		if err != nil {
 			if debug {
 				fmt.Printf("[DEBUG] HTTP error: %v\n", err)
 			}
+			fetchErr = err
 			break
 		}
 
@@ -87,6 +269,7 @@ func fetchAllInventory(steamID, appID, contextID string, debug bool) ([]Asset, [
 			if debug {
 				fmt.Printf("[DEBUG] Status code error: %d %s\n", resp.StatusCode, resp.Status)
 			}
+			fetchErr = fmt.Errorf("inventory status: %s", resp.Status)
 			break
 		}
 
@@ -128,7 +311,6 @@ func fetchAllInventory(steamID, appID, contextID string, debug bool) ([]Asset, [
 		}
 
 		startAssetID = inventory.LastAssetID
-		time.Sleep(1 * time.Second)
 	}
 
 	for _, desc := range descMap {
@@ -140,30 +322,52 @@ func fetchAllInventory(steamID, appID, contextID string, debug bool) ([]Asset, [
 			len(allAssets), len(allDescriptions))
 	}
 
-	return allAssets, allDescriptions, totalCount
+	return allAssets, allDescriptions, totalCount, fetchErr
 }
 
-func getMarketPrice(appID string, marketHashName string, debug bool) string {
-	encodedName := url.QueryEscape(marketHashName)
-	marketURL := fmt.Sprintf("https://steamcommunity.com/market/priceoverview/?appid=%s&currency=5&market_hash_name=%s", appID, encodedName)
+// Цены предмета на торговой площадке в формате Steam (например, "1 234,56 руб.")
+type MarketPrice struct {
+	Lowest string `json:"lowest"`
+	Median string `json:"median"`
+	Volume string `json:"volume"` // продано за последние сутки
+}
 
-	client := &http.Client{
-		Timeout: 30 * time.Second,
+// Запрашиваем цену предмета. Пустая строка без ошибки означает, что цены нет;
+// ошибка возвращается только при сбое запроса
+func (sc *SteamClient) getMarketPrice(ctx context.Context, appID string, marketHashName string, debug bool) (string, error) {
+	overview, err := sc.getMarketOverview(ctx, appID, marketHashName, debug)
+	if err != nil || overview.Lowest == "" {
+		return "", err
 	}
+	return fmt.Sprintf("%s (lowest)", overview.Lowest), nil
+}
 
-	req, err := http.NewRequest("GET", marketURL, nil)
-	if err != nil {
-		return ""
+// Минимальная и медианная цены и объем продаж предмета (общий кэш цен)
+func (sc *SteamClient) getMarketOverview(ctx context.Context, appID string, marketHashName string, debug bool) (MarketPrice, error) {
+	cacheKey := appID + "_" + marketHashName
+	if overview, cached := sc.prices.Get(cacheKey); cached {
+		return overview, nil
 	}
 
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36")
+	overview, err := sc.fetchMarketOverview(ctx, appID, marketHashName, debug)
+	if err == nil {
+		// Отсутствие цены тоже кэшируем, чтобы не спрашивать Steam повторно
+		sc.prices.Set(cacheKey, overview)
+	}
 
-	resp, err := client.Do(req)
+	return overview, err
+}
+
+func (sc *SteamClient) fetchMarketOverview(ctx context.Context, appID string, marketHashName string, debug bool) (MarketPrice, error) {
+	encodedName := url.QueryEscape(marketHashName)
+	marketURL := fmt.Sprintf("https://steamcommunity.com/market/priceoverview/?appid=%s&currency=%d&market_hash_name=%s", appID, marketCurrency, encodedName)
+
+	resp, err := sc.get(ctx, EndpointMarket, marketURL)
 	if err != nil {
 		if debug {
 			fmt.Printf("[DEBUG] Market API error: %v\n", err)
 		}
-		return ""
+		return MarketPrice{}, err
 	}
 	defer resp.Body.Close()
 
@@ -171,24 +375,23 @@ func getMarketPrice(appID string, marketHashName string, debug bool) string {
 		if debug {
 			fmt.Printf("[DEBUG] Market API status: %d %s\n", resp.StatusCode, resp.Status)
 		}
-		return ""
+		return MarketPrice{}, fmt.Errorf("market status: %s", resp.Status)
 	}
 
 	var priceResp MarketPriceResponse
 	if err := json.NewDecoder(resp.Body).Decode(&priceResp); err != nil {
-		return ""
+		return MarketPrice{}, nil
 	}
 
 	if !priceResp.Success {
-		return ""
-	}
-
-	if priceResp.LowestPrice != "" {
-		price := strings.TrimSpace(priceResp.LowestPrice)
-		return fmt.Sprintf("%s (lowest)", price)
+		return MarketPrice{}, nil
 	}
 
-	return ""
+	return MarketPrice{
+		Lowest: strings.TrimSpace(priceResp.LowestPrice),
+		Median: strings.TrimSpace(priceResp.MedianPrice),
+		Volume: strings.TrimSpace(priceResp.Volume),
+	}, nil
 }
 
 func parsePrice(priceStr string) float64 {
@@ -204,7 +407,13 @@ func parsePrice(priceStr string) float64 {
 	return value
 }
 
-func resolveSteamID(input string) string {
+// Объем продаж: "1,234" -> 1234
+func parseVolume(volume string) int {
+	value, _ := strconv.Atoi(strings.NewReplacer(",", "", ".", "", " ", "").Replace(volume))
+	return value
+}
+
+func (sc *SteamClient) resolveSteamID(ctx context.Context, input string) string {
 	input = strings.TrimSpace(input)
 
 	if regexp.MustCompile(`^\d+$`).MatchString(input) {
@@ -219,28 +428,21 @@ func resolveSteamID(input string) string {
 	vanityRe := regexp.MustCompile(`id/([^/]+)`)
 	if matches := vanityRe.FindStringSubmatch(input); len(matches) >= 2 {
 		vanityName := matches[1]
-		return getSteamIDFromVanity(vanityName)
+		return sc.getSteamIDFromVanity(ctx, vanityName)
 	}
 
-	return getSteamIDFromVanity(input)
+	return sc.getSteamIDFromVanity(ctx, input)
 }
 
-func getSteamIDFromVanity(vanityName string) string {
+func (sc *SteamClient) getSteamIDFromVanity(ctx context.Context, vanityName string) string {
 	vanityName = strings.TrimSpace(vanityName)
-	xmlURL := fmt.Sprintf("https://steamcommunity.com/id/%s/?xml=1", vanityName)
-
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-	}
 
-	req, err := http.NewRequest("GET", xmlURL, nil)
-	if err != nil {
-		return ""
+	if steamID, cached := sc.profiles.Get(vanityName); cached {
+		return steamID
 	}
+	xmlURL := fmt.Sprintf("https://steamcommunity.com/id/%s/?xml=1", vanityName)
 
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36")
-
-	resp, err := client.Do(req)
+	resp, err := sc.get(ctx, EndpointProfile, xmlURL)
 	if err != nil {
 		return ""
 	}
@@ -258,6 +460,7 @@ func getSteamIDFromVanity(vanityName string) string {
 		return ""
 	}
 
+	sc.profiles.Set(vanityName, matches[1])
 	return matches[1]
 }
 
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+)
+
+// Записи хранятся, пока их не удалят; срок в хранилище - просто большой запас
+const storeRetention = 10 * 365 * 24 * time.Hour
+
+// Что сделать с записью после Update
+type storeAction int
+
+const (
+	storeKeep   storeAction = iota // запись не менялась
+	storeSave                      // сохранить изменения
+	storeDelete                    // удалить запись
+)
+
+// Записи по ключу в памяти и в постоянном хранилище: задачи планировщика,
+// правила, привязанные аккаунты. Запись в хранилище идет под mutex, чтобы
+// сохранения одной записи не обгоняли друг друга
+type Store[V any] struct {
+	mutex   sync.Mutex
+	name    string // что хранится, для журнала: "правил", "аккаунтов"
+	items   map[string]V
+	backend CacheBackend
+}
+
+func NewStore[V any](name string, backend CacheBackend) *Store[V] {
+	store := &Store[V]{
+		name:    name,
+		items:   make(map[string]V),
+		backend: backend,
+	}
+
+	if backend != nil {
+		store.restore()
+	}
+
+	return store
+}
+
+// Загружаем сохраненные записи
+func (s *Store[V]) restore() {
+	stored, err := s.backend.Load()
+	if err != nil {
+		log.Printf("Ошибка загрузки %s: %v", s.name, err)
+	}
+
+	for _, record := range stored {
+		var value V
+		if err := json.Unmarshal(record.Value, &value); err != nil {
+			continue
+		}
+		s.items[record.Key] = value
+	}
+
+	if len(s.items) > 0 {
+		log.Printf("Восстановлено %s: %d", s.name, len(s.items))
+	}
+}
+
+func (s *Store[V]) Get(key string) (V, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	value, exists := s.items[key]
+	return value, exists
+}
+
+// Добавляем или заменяем запись
+func (s *Store[V]) Set(key string, value V) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.items[key] = value
+	s.persist(key, value)
+}
+
+// Изменяем запись на месте. update получает копию записи (или нулевое значение,
+// если ее нет) и решает, сохранить, удалить или оставить ее как есть
+func (s *Store[V]) Update(key string, update func(value *V, exists bool) storeAction) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	value, exists := s.items[key]
+	switch update(&value, exists) {
+	case storeSave:
+		s.items[key] = value
+		s.persist(key, value)
+	case storeDelete:
+		if exists {
+			delete(s.items, key)
+			s.remove(key)
+		}
+	}
+}
+
+// Удаляем запись. Возвращает false, если ее не было
+func (s *Store[V]) Delete(key string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	_, exists := s.items[key]
+	if exists {
+		delete(s.items, key)
+		s.remove(key)
+	}
+	return exists
+}
+
+// Записи, подходящие под условие (match может быть nil), в произвольном порядке
+func (s *Store[V]) Values(match func(value V) bool) []V {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var values []V
+	for _, value := range s.items {
+		if match == nil || match(value) {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// Записываем запись в хранилище (вызывать под mutex)
+func (s *Store[V]) persist(key string, value V) {
+	if s.backend == nil {
+		return
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		log.Printf("Ошибка сериализации %s: %v", s.name, err)
+		return
+	}
+
+	now := time.Now()
+	err = s.backend.Save(StoredEntry{
+		Key:         key,
+		Value:       data,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(storeRetention),
+		DeleteAfter: now.Add(storeRetention),
+	})
+	if err != nil {
+		log.Printf("Ошибка сохранения %s: %v", s.name, err)
+	}
+}
+
+// Удаляем запись из хранилища (вызывать под mutex)
+func (s *Store[V]) remove(key string) {
+	if s.backend == nil {
+		return
+	}
+	if err := s.backend.Delete(key); err != nil {
+		log.Printf("Ошибка удаления %s: %v", s.name, err)
+	}
+}
+
+func (s *Store[V]) Close() {
+	if s.backend == nil {
+		return
+	}
+	if err := s.backend.Close(); err != nil {
+		log.Printf("Ошибка закрытия хранилища %s: %v", s.name, err)
+	}
+}
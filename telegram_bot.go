@@ -1,9 +1,13 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
@@ -11,18 +15,70 @@ import (
 
 // InventoryItem представляет предмет в инвентаре для GUI
 type InventoryItem struct {
-	Name       string  `json:"name"`
-	MarketName string  `json:"market_name"`
-	Type       string  `json:"type"`
-	Price      string  `json:"price"`
-	PriceValue float64 `json:"price_value"`
-	AssetID    string  `json:"asset_id"`
+	Name           string  `json:"name"`
+	MarketName     string  `json:"market_name"`
+	MarketHashName string  `json:"market_hash_name"`
+	Type           string  `json:"type"`
+	Price          string  `json:"price"`
+	PriceValue     float64 `json:"price_value"`
+	Volume         int     `json:"volume,omitempty"` // продано на площадке за сутки
+	AssetID        string  `json:"asset_id"`
+	Amount         int     `json:"amount"`
+	Tradable       bool    `json:"tradable"`
+	Tags           []Tag   `json:"tags,omitempty"`
+	IconURL        string  `json:"icon_url,omitempty"`
+	IconURLLarge   string  `json:"icon_url_large,omitempty"`
+	InspectLink    string  `json:"inspect_link,omitempty"` // шаблон с %owner_steamid% и %assetid%
+	NameColor      string  `json:"name_color,omitempty"`
+}
+
+// Значение тега категории category (например, "Rarity"), пустая строка если тега нет
+func (item InventoryItem) Tag(category string) string {
+	for _, tag := range item.Tags {
+		if strings.EqualFold(tag.Category, category) {
+			return tag.LocalizedTagName
+		}
+	}
+	return ""
+}
+
+// Количество предметов в стопке (у большинства предметов 1)
+func (item InventoryItem) Quantity() int {
+	if item.Amount < 1 {
+		return 1
+	}
+	return item.Amount
+}
+
+// Стоимость всей стопки
+func (item InventoryItem) Value() float64 {
+	return item.PriceValue * float64(item.Quantity())
 }
 
 type TelegramBot struct {
-	bot         *tgbotapi.BotAPI
-	cache       *Cache
-	rateLimiter *RateLimiter
+	bot          *tgbotapi.BotAPI
+	cache        *Cache[*ScanResult]
+	steam        *SteamClient
+	scanQuota    *UserQuota
+	priceQuota   *UserQuota
+	refreshQuota *UserQuota
+	icons        *IconStore
+	snapshots    *SnapshotStore
+	scheduler    *Scheduler
+	rules        *RuleStore
+	accounts     *AccountStore
+
+	queue      *ScanQueue
+	queueMutex sync.Mutex
+	inflight   map[string]*ScanJob
+
+	// Ключ кэша последнего результата, показанного в чате (для /find)
+	lastScans      map[int64]string
+	lastScansMutex sync.Mutex
+
+	// Пользователи, для которых сейчас собирается портфель
+	portfolios      map[int64]bool
+	portfoliosMutex sync.Mutex
 }
 
 func NewTelegramBot(token string) (*TelegramBot, error) {
@@ -34,15 +90,86 @@ func NewTelegramBot(token string) (*TelegramBot, error) {
 	bot.Debug = false
 	log.Printf("Авторизован как %s", bot.Self.UserName)
 
-	// Создаем кэш на 30 минут и rate limiter на 3 секунды
-	cache := NewCache(30 * time.Minute)
-	rateLimiter := NewRateLimiter(3 * time.Second)
+	// Создаем кэш на 30 минут и лимиты для каждого эндпоинта Steam
+	// Результаты сканирований сохраняются в хранилище и переживают перезапуск
+	backend, err := newCacheBackendFromEnv("inventory")
+	if err != nil {
+		log.Printf("Хранилище кэша недоступно, кэш только в памяти: %v", err)
+		backend = nil
+	}
 
-	return &TelegramBot{
-		bot:         bot,
-		cache:       cache,
-		rateLimiter: rateLimiter,
-	}, nil
+	// Устаревший результат еще 6 часов отдается, пока инвентарь пересканируется в фоне
+	cache := NewCache(CacheOptions[*ScanResult]{
+		TTL:        30 * time.Minute,
+		StaleTTL:   6 * time.Hour,
+		MaxEntries: 500,
+		MaxBytes:   64 << 20,
+		SizeOf:     (*ScanResult).Size,
+		Backend:    backend,
+	})
+	// Снимки инвентарей для истории хранятся в том же хранилище, что и кэш
+	snapshotBackend, err := newCacheBackendFromEnv("snapshots")
+	if err != nil {
+		log.Printf("Хранилище снимков недоступно, история только в памяти: %v", err)
+		snapshotBackend = nil
+	}
+	// Задачи планировщика (наблюдение за профилями) тоже переживают перезапуск
+	scheduleBackend, err := newCacheBackendFromEnv("schedule")
+	if err != nil {
+		log.Printf("Хранилище задач недоступно, задачи только в памяти: %v", err)
+		scheduleBackend = nil
+	}
+	ruleBackend, err := newCacheBackendFromEnv("rules")
+	if err != nil {
+		log.Printf("Хранилище правил недоступно, правила только в памяти: %v", err)
+		ruleBackend = nil
+	}
+	accountBackend, err := newCacheBackendFromEnv("accounts")
+	if err != nil {
+		log.Printf("Хранилище аккаунтов недоступно, аккаунты только в памяти: %v", err)
+		accountBackend = nil
+	}
+
+	rateLimiter := NewRateLimiter(map[Endpoint]Limit{
+		EndpointInventory: {Rate: 0.5, Burst: 3},
+		EndpointMarket:    {Rate: 1.0 / 3, Burst: 5},
+		EndpointProfile:   {Rate: 1, Burst: 5},
+	})
+
+	tb := &TelegramBot{
+		bot:          bot,
+		cache:        cache,
+		steam:        NewSteamClient(rateLimiter),
+		scanQuota:    NewUserQuota(10, time.Hour),
+		priceQuota:   NewUserQuota(10, time.Minute),
+		refreshQuota: NewUserQuota(3, time.Hour),
+		icons:        NewIconStore(iconCacheDir()),
+		snapshots:    NewSnapshotStore(snapshotBackend),
+		scheduler:    NewScheduler(scheduleBackend),
+		rules:        NewRuleStore(ruleBackend),
+		accounts:     NewAccountStore(accountBackend),
+		queue:        NewScanQueue(50, 3, scanWorkers),
+		inflight:     make(map[string]*ScanJob),
+		lastScans:    make(map[int64]string),
+		portfolios:   make(map[int64]bool),
+	}
+
+	tb.scheduler.Handle(watchKind, tb.runWatch)
+	tb.scheduler.Handle(alertKind, tb.runAlert)
+	tb.scheduler.Handle(digestKind, tb.runDigest)
+	tb.scheduler.Handle(liveKind, tb.runLive)
+
+	return tb, nil
+}
+
+// Останавливаем планировщик и фоновые горутины кэшей
+func (tb *TelegramBot) Close() {
+	tb.scheduler.Close()
+	tb.cache.Close()
+	tb.steam.Close()
+	tb.snapshots.Close()
+	tb.rules.Close()
+	tb.accounts.Close()
 }
 
 func (tb *TelegramBot) Start() {
@@ -51,6 +178,11 @@ func (tb *TelegramBot) Start() {
 
 	updates := tb.bot.GetUpdatesChan(u)
 
+	for i := 0; i < scanWorkers; i++ {
+		go tb.scanWorker()
+	}
+	tb.scheduler.Start()
+
 	for update := range updates {
 		if update.Message != nil {
 			tb.handleMessage(update.Message)
@@ -64,20 +196,66 @@ func (tb *TelegramBot) handleMessage(message *tgbotapi.Message) {
 	chatID := message.Chat.ID
 	text := message.Text
 
+	userID := chatID
+	if message.From != nil {
+		userID = message.From.ID
+	}
+
 	// Игнорируем старые сообщения
 	if time.Since(message.Time()) > 5*time.Minute {
 		return
 	}
 
+	if message.Document != nil {
+		tb.handleDocument(chatID, userID, message.Document)
+		return
+	}
+
 	switch {
 	case text == "/start":
 		tb.sendWelcomeMessage(chatID)
 	case text == "/help":
 		tb.sendHelpMessage(chatID)
 	case strings.HasPrefix(text, "/scan"):
-		tb.handleScanCommand(chatID, text)
+		tb.handleScanCommand(chatID, userID, text)
+	case strings.HasPrefix(text, "/refresh"):
+		tb.handleRefreshCommand(chatID, userID, text)
 	case strings.HasPrefix(text, "/price"):
-		tb.handlePriceCommand(chatID, text)
+		tb.handlePriceCommand(chatID, userID, text)
+	case text == "/status":
+		tb.sendStatusMessage(chatID)
+	case strings.HasPrefix(text, "/find"):
+		tb.handleFindCommand(chatID, text)
+	case strings.HasPrefix(text, "/history"):
+		tb.handleHistoryCommand(chatID, text)
+	case strings.HasPrefix(text, "/diff"):
+		tb.handleDiffCommand(chatID, text)
+	case strings.HasPrefix(text, "/export"):
+		tb.handleExportCommand(chatID, text)
+	case strings.HasPrefix(text, "/watch"):
+		tb.handleWatchCommand(chatID, userID, text)
+	case strings.HasPrefix(text, "/unwatch"):
+		tb.handleUnwatchCommand(chatID, text)
+	case text == "/alerts":
+		tb.sendAlerts(chatID)
+	case strings.HasPrefix(text, "/alert"):
+		tb.handleAlertCommand(chatID, userID, text)
+	case strings.HasPrefix(text, "/digest"):
+		tb.handleDigestCommand(chatID, userID, text)
+	case strings.HasPrefix(text, "/live"):
+		tb.handleLiveCommand(chatID, userID, text)
+	case text == "/rules":
+		tb.sendRules(chatID)
+	case strings.HasPrefix(text, "/rule"):
+		tb.handleRuleCommand(chatID, userID, text)
+	case strings.HasPrefix(text, "/link"):
+		tb.handleLinkCommand(chatID, userID, text)
+	case strings.HasPrefix(text, "/unlink"):
+		tb.handleUnlinkCommand(chatID, userID, text)
+	case text == "/accounts":
+		tb.sendAccounts(chatID, userID)
+	case text == "/portfolio":
+		tb.handlePortfolioCommand(chatID, userID)
 	default:
 		// Если сообщение похоже на Steam ID или ссылку
 		if tb.isSteamInput(text) {
@@ -90,6 +268,7 @@ func (tb *TelegramBot) handleMessage(message *tgbotapi.Message) {
 
 func (tb *TelegramBot) handleCallback(callback *tgbotapi.CallbackQuery) {
 	chatID := callback.Message.Chat.ID
+	userID := callback.From.ID
 	data := callback.Data
 
 	// Отвечаем на callback
@@ -102,7 +281,70 @@ func (tb *TelegramBot) handleCallback(callback *tgbotapi.CallbackQuery) {
 		if len(parts) >= 3 {
 			steamID := parts[1]
 			appID := parts[2]
-			tb.scanInventory(chatID, steamID, appID)
+			tb.enqueueScan(chatID, userID, steamID, appID, false)
+		}
+	case strings.HasPrefix(data, "browsenew_"):
+		if state, ok := parseBrowserState(data); ok {
+			tb.openBrowser(chatID, state)
+		}
+	case strings.HasPrefix(data, "browse_"):
+		if state, ok := parseBrowserState(data); ok {
+			tb.updateBrowser(chatID, callback.Message.MessageID, state)
+		}
+	case strings.HasPrefix(data, "item_"):
+		parts := strings.Split(data, "_")
+		if len(parts) >= 4 {
+			// Цена может запрашиваться у площадки: не задерживаем другие обновления
+			go tb.sendItemCard(chatID, userID, parts[1], parts[2], parts[3])
+		}
+	case strings.HasPrefix(data, "inspect_"):
+		parts := strings.Split(data, "_")
+		if len(parts) >= 4 {
+			tb.sendInspectLink(chatID, parts[1], parts[2], parts[3])
+		}
+	case strings.HasPrefix(data, "showcase_"):
+		parts := strings.Split(data, "_")
+		if len(parts) >= 3 {
+			// Рисование и загрузка иконок занимают время: не задерживаем другие обновления
+			go tb.sendShowcase(chatID, parts[1], parts[2])
+		}
+	case strings.HasPrefix(data, "charts_"):
+		parts := strings.Split(data, "_")
+		if len(parts) >= 3 {
+			go tb.sendCharts(chatID, parts[1], parts[2])
+		}
+	case strings.HasPrefix(data, "historychart_"):
+		parts := strings.Split(data, "_")
+		if len(parts) >= 3 {
+			go tb.sendHistoryChart(chatID, parts[1], parts[2])
+		}
+	case strings.HasPrefix(data, "exportfile_"):
+		parts := strings.Split(data, "_")
+		if len(parts) >= 4 && isExportFormat(parts[1]) {
+			if result, ok := tb.scanResult(chatID, parts[2], parts[3]); ok {
+				tb.sendExport(chatID, result, parts[1])
+			}
+		}
+	case strings.HasPrefix(data, "export_"):
+		parts := strings.Split(data, "_")
+		if len(parts) >= 3 {
+			tb.sendExportFormats(chatID, parts[1], parts[2])
+		}
+	case strings.HasPrefix(data, "alertdel_"):
+		tb.deleteAlert(chatID, callback.Message.MessageID, strings.TrimPrefix(data, "alertdel_"))
+	case strings.HasPrefix(data, "ruledel_"):
+		tb.deleteRule(chatID, callback.Message.MessageID, strings.TrimPrefix(data, "ruledel_"))
+	case strings.HasPrefix(data, "unlink_"):
+		parts := strings.Split(data, "_")
+		if len(parts) >= 3 {
+			if ownerID, err := strconv.ParseInt(parts[1], 10, 64); err == nil {
+				tb.unlinkAccount(chatID, userID, ownerID, callback.Message.MessageID, parts[2])
+			}
+		}
+	case strings.HasPrefix(data, "refresh_"):
+		parts := strings.Split(data, "_")
+		if len(parts) >= 3 {
+			tb.enqueueScan(chatID, userID, parts[1], parts[2], true)
 		}
 	case data == "help":
 		tb.sendHelpMessage(chatID)
@@ -116,7 +358,20 @@ func (tb *TelegramBot) sendWelcomeMessage(chatID int64) {
 
 *Доступные команды:*
 /scan - Сканировать инвентарь
+/refresh - Пересканировать без кэша
 /price - Проверить цену предмета
+/status - Состояние сервисов Steam
+/find - Поиск по инвентарю
+/export - Экспорт в CSV, JSON, XLSX или HTML
+/history - История стоимости инвентаря
+/diff - Изменения между снимками
+/watch - Следить за профилем
+/alert - Оповещение о цене предмета
+/rule - Правила для наблюдаемых профилей
+/digest - Ежедневная или еженедельная сводка
+/live - Закрепленное сообщение со стоимостью портфеля
+/link - Привязать свой Steam аккаунт
+/portfolio - Стоимость всех привязанных аккаунтов
 /help - Справка
 
 *Как использовать:*
@@ -139,10 +394,61 @@ func (tb *TelegramBot) sendHelpMessage(chatID int64) {
 Использование: /scan <steam_id> [app_id]
 Пример: /scan 76561198111717059 730
 
+*/refresh* - Пересканировать инвентарь, не используя кэш
+Использование: /refresh <steam_id> [app_id]
+
 */price* - Проверить цену предмета
 Использование: /price <market_hash_name>
 Пример: /price "AK-47 | Redline (Field-Tested)"
 
+*/status* - Состояние сервисов Steam и очереди бота
+
+*/find* - Поиск по последнему сканированию
+Использование: /find <запрос>
+Фильтры: type:, rarity:, exterior:, tradable:no, price>100, price<=500
+Пример: /find type:knife price>5000
+
+*/export* - Выгрузить последнее сканирование файлом
+Использование: /export csv|json|xlsx|html
+
+*/history* - История стоимости инвентаря по снимкам
+Использование: /history <steam_id> [app_id]
+
+*/diff* - Что изменилось между снимками: новые и ушедшие предметы, цены
+Использование: /diff <steam_id> [app_id] [7d|24h]
+
+*/watch* - Следить за профилем: бот сам пересканирует его и сообщит об изменениях
+Использование: /watch <steam_id> [app_id], /watch - список
+Настройки: /watch interval <часы>, /watch threshold <процент>
+*/unwatch* - Перестать следить: /unwatch <steam_id> [app_id]
+
+*/alert* - Оповещение, когда цена предмета пересечет порог или изменится
+Использование: /alert <market_hash_name> above|below <цена> [median|lowest] [app\_id]
+или: /alert <market_hash_name> change [+|-]<процент> [median|lowest] [app\_id]
+Пример: /alert AK-47 | Redline (Field-Tested) below 1200
+*/alerts* - Список оповещений с кнопками удаления
+
+*/rule* - Правило для профилей под наблюдением, проверяется после каждого сканирования
+Использование: /rule <условие>, /rule - список метрик
+Пример: /rule change\_24h <= -10
+*/rules* - Список правил с кнопками удаления
+
+*/digest* - Сводка по наблюдаемым профилям: стоимость, изменения цен, новые и ушедшие предметы, сработавшие оповещения и правила (одна на пользователя)
+Использование: /digest daily|weekly ЧЧ:ММ [часовой пояс], /digest off
+Пример: /digest daily 09:00 Europe/Moscow
+
+*/live* - Закрепленное сообщение со стоимостью наблюдаемых профилей, обновляется каждые 10 минут
+Отключить: /live off
+
+*/link* - Привязать Steam аккаунт, чтобы считать общую стоимость
+Использование: /link <steam\_id> [подпись]
+Пример: /link 76561198111717059 Склад
+*/unlink* - Отвязать аккаунт: /unlink <steam\_id или подпись>
+*/accounts* - Привязанные аккаунты с кнопками отвязки
+*/portfolio* - Общая стоимость всех привязанных аккаунтов во всех играх
+
+*Импорт:* пришлите JSON-файл инвентаря (ответ steamcommunity.com/inventory/...), если профиль закрыт или Steam недоступен
+
 *Поддерживаемые игры:*
 • CS:GO (730)
 • Dota 2 (570)
@@ -157,23 +463,47 @@ func (tb *TelegramBot) sendHelpMessage(chatID int64) {
 	tb.sendMessage(chatID, text)
 }
 
-func (tb *TelegramBot) handleScanCommand(chatID int64, text string) {
+func (tb *TelegramBot) handleScanCommand(chatID, userID int64, text string) {
 	parts := strings.Fields(text)
 	if len(parts) < 2 {
 		tb.sendMessage(chatID, "Использование: /scan <steam_id> [app_id]")
 		return
 	}
 
-	steamID := parts[1]
+	steamID, ok := tb.resolveInput(chatID, parts[1])
+	if !ok {
+		return
+	}
+
 	appID := "730" // CS:GO по умолчанию
 	if len(parts) > 2 {
 		appID = parts[2]
 	}
 
-	tb.scanInventory(chatID, steamID, appID)
+	tb.enqueueScan(chatID, userID, steamID, appID, false)
 }
 
-func (tb *TelegramBot) handlePriceCommand(chatID int64, text string) {
+func (tb *TelegramBot) handleRefreshCommand(chatID, userID int64, text string) {
+	parts := strings.Fields(text)
+	if len(parts) < 2 {
+		tb.sendMessage(chatID, "Использование: /refresh <steam\\_id> [app\\_id]")
+		return
+	}
+
+	steamID, ok := tb.resolveInput(chatID, parts[1])
+	if !ok {
+		return
+	}
+
+	appID := "730" // CS:GO по умолчанию
+	if len(parts) > 2 {
+		appID = parts[2]
+	}
+
+	tb.enqueueScan(chatID, userID, steamID, appID, true)
+}
+
+func (tb *TelegramBot) handlePriceCommand(chatID, userID int64, text string) {
 	parts := strings.Fields(text)
 	if len(parts) < 2 {
 		tb.sendMessage(chatID, "Использование: /price <market_hash_name>")
@@ -183,9 +513,21 @@ func (tb *TelegramBot) handlePriceCommand(chatID int64, text string) {
 	marketName := strings.Join(parts[1:], " ")
 	appID := "730" // CS:GO по умолчанию
 
+	if ok, wait := tb.priceQuota.Allow(userID); !ok {
+		tb.sendMessage(chatID, "⏳ Слишком много запросов цен. Попробуйте снова через "+formatWait(wait)+".")
+		return
+	}
+
 	tb.sendMessage(chatID, "🔍 Проверяю цену...")
 
-	price := getMarketPrice(appID, marketName, false)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	price, err := tb.steam.getMarketPrice(ctx, appID, marketName, false)
+	if errors.Is(err, ErrCircuitOpen) {
+		tb.sendMessage(chatID, "🔌 Торговая площадка Steam сейчас недоступна. Попробуйте позже.")
+		return
+	}
 	if price == "" {
 		tb.sendMessage(chatID, "❌ Не удалось получить цену для: "+marketName)
 		return
@@ -196,10 +538,8 @@ func (tb *TelegramBot) handlePriceCommand(chatID int64, text string) {
 }
 
 func (tb *TelegramBot) handleSteamInput(chatID int64, text string) {
-	// Разрешаем Steam ID
-	resolvedID := resolveSteamID(text)
-	if resolvedID == "" {
-		tb.sendMessage(chatID, "❌ Не удалось распознать Steam ID")
+	resolvedID, ok := tb.resolveInput(chatID, text)
+	if !ok {
 		return
 	}
 
@@ -207,6 +547,24 @@ func (tb *TelegramBot) handleSteamInput(chatID int64, text string) {
 	tb.sendGameSelection(chatID, resolvedID)
 }
 
+// Разрешаем Steam ID из ввода пользователя; при ошибке сообщаем о ней в чат
+func (tb *TelegramBot) resolveInput(chatID int64, input string) (string, bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	resolvedID := tb.steam.resolveSteamID(ctx, input)
+	if resolvedID == "" {
+		if !tb.steam.Available(EndpointProfile) {
+			tb.sendMessage(chatID, "🔌 Сервис профилей Steam сейчас недоступен. Попробуйте позже.")
+			return "", false
+		}
+		tb.sendMessage(chatID, "❌ Не удалось распознать Steam ID")
+		return "", false
+	}
+
+	return resolvedID, true
+}
+
 func (tb *TelegramBot) sendGameSelection(chatID int64, steamID string) {
 	text := "🎮 Выберите игру для сканирования:"
 
@@ -228,194 +586,290 @@ func (tb *TelegramBot) sendGameSelection(chatID int64, steamID string) {
 	tb.bot.Send(msg)
 }
 
-func (tb *TelegramBot) scanInventory(chatID int64, steamID, appID string) {
-	// Создаем ключ для кэша
-	cacheKey := fmt.Sprintf("%s_%s", steamID, appID)
-
-	// Проверяем кэш
-	if cachedData, exists := tb.cache.Get(cacheKey); exists {
-		tb.sendMessage(chatID, "⚡ Использую кэшированные данные...")
-		
-		// Формируем отчет из кэшированных данных
-		var totalValue, minPrice, maxPrice float64
-		var minItem, maxItem string
-		
-		for i, item := range cachedData {
-			if i == 0 {
-				minPrice = item.PriceValue
-				maxPrice = item.PriceValue
-				minItem = item.Name
-				maxItem = item.Name
-			}
-			
-			totalValue += item.PriceValue
-			
-			if item.PriceValue < minPrice {
-				minPrice = item.PriceValue
-				minItem = item.Name
-			}
-			if item.PriceValue > maxPrice {
-				maxPrice = item.PriceValue
-				maxItem = item.Name
+// Выполняем задание на сканирование. Прогресс и результат получают все чаты,
+// подписанные на задание
+func (tb *TelegramBot) scanInventory(job *ScanJob) {
+	steamID, appID := job.SteamID, job.AppID
+
+	// Загруженный файл оцениваем без запросов инвентаря к Steam
+	if job.Import != nil {
+		tb.scanImported(job)
+		return
+	}
+
+	// Проверяем кэш (фоновое обновление, плановое и принудительное сканирование всегда сканируют заново,
+	// портфель - если кэш устарел)
+	entry, exists := tb.cache.GetEntry(job.Key)
+	if exists && !job.Refresh && !job.Scheduled && !job.Force && !(job.Portfolio && entry.Stale()) {
+		// Устаревшие данные отдаем сразу, а инвентарь обновляем в фоне.
+		// Загруженный файл обновить неоткуда
+		source := "из кэша"
+		if entry.Stale() && !isImportID(steamID) {
+			source = "из кэша, обновляется"
+			if tb.cache.BeginRefresh(job.Key) {
+				tb.enqueueRefresh(steamID, appID)
 			}
 		}
-		
-		gameName := getGameName(appID)
-		response := fmt.Sprintf(`📊 *Статистика инвентаря %s* (из кэша)
-
-🎮 Игра: %s
-📦 Всего предметов: %d
-💵 Общая стоимость: %.2f ₽
-
-📈 *Ценовая статистика:*
-• Минимальная: %.2f ₽ (%s)
-• Максимальная: %.2f ₽ (%s)`,
-			steamID, gameName, len(cachedData), totalValue,
-			minPrice, minItem, maxPrice, maxItem)
-		
-		tb.sendMessage(chatID, response)
-		
-		// Показываем топ-5 самых дорогих предметов
-		if len(cachedData) > 0 {
-			tb.sendTopItems(chatID, cachedData)
+
+		job.result = entry.Value
+		for _, chatID := range tb.closeJob(job) {
+			tb.sendMessage(chatID, "⚡ Использую кэшированные данные...")
+			tb.sendScanResult(chatID, entry.Value, source)
 		}
 		return
 	}
 
-	tb.sendMessage(chatID, "🔍 Сканирую инвентарь...")
+	// Оценку файла можно получить только из кэша: в Steam такого профиля нет
+	if isImportID(steamID) {
+		tb.finishJob(job, "📄 Оценка этого файла больше не хранится. Пришлите файл снова.")
+		return
+	}
+
+	// Пока задание ждало в очереди, Steam мог стать недоступен
+	if !tb.steam.Available(EndpointInventory) {
+		tb.finishJob(job, inventoryUnavailableText)
+		return
+	}
+
+	tb.broadcast(job, "🔍 Сканирую инвентарь...")
 
 	startTime := time.Now()
+	tb.notifyThrottled(job)
 
-	// Ждем разрешения от rate limiter
-	tb.rateLimiter.Wait()
+	// Добавляем таймаут для сканирования (2 минуты)
+	fetchCtx, cancelFetch := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancelFetch()
 
 	// Разрешаем Steam ID
-	resolvedID := resolveSteamID(steamID)
+	resolvedID := tb.steam.resolveSteamID(fetchCtx, steamID)
 	if resolvedID == "" {
-		tb.sendMessage(chatID, "❌ Не удалось разрешить Steam ID")
+		if !tb.steam.Available(EndpointProfile) {
+			tb.finishJob(job, "🔌 Сервис профилей Steam сейчас недоступен. Попробуйте позже.")
+			return
+		}
+		tb.finishJob(job, "❌ Не удалось разрешить Steam ID")
 		return
 	}
 
-	// Добавляем таймаут для сканирования
-	done := make(chan bool, 1)
-	var assets []Asset
-	var descriptions []Description
-	var totalCount int
-
-	go func() {
-		contextID := "2"
-		assets, descriptions, totalCount = fetchAllInventory(resolvedID, appID, contextID, false)
-		done <- true
-	}()
-
-	// Ждем завершения или таймаута (2 минуты)
-	select {
-	case <-done:
-		// Сканирование завершено
-	case <-time.After(2 * time.Minute):
-		tb.sendMessage(chatID, "⏰ Таймаут сканирования. Инвентарь слишком большой или недоступен.")
+	assets, descriptions, totalCount, err := tb.steam.fetchAllInventory(fetchCtx, resolvedID, appID, defaultContextID, false)
+
+	if errors.Is(err, ErrCircuitOpen) {
+		tb.finishJob(job, inventoryUnavailableText)
+		return
+	}
+
+	if fetchCtx.Err() != nil {
+		tb.finishJob(job, scanTimeoutText)
 		return
 	}
 
 	if totalCount == 0 {
-		tb.sendMessage(chatID, "❌ Инвентарь пуст или недоступен")
+		tb.finishJob(job, emptyInventoryText)
 		return
 	}
 
-	tb.sendMessage(chatID, fmt.Sprintf("📦 Найдено %d предметов. Обрабатываю цены...", totalCount))
+	result := &ScanResult{
+		SteamID:         steamID,
+		AppID:           appID,
+		TotalCount:      totalCount,
+		FetchedCount:    len(assets),
+		MarketableCount: countMarketable(assets, descriptions),
+		FetchPartial:    err != nil || len(assets) < totalCount,
+		ScannedAt:       startTime,
+	}
+
+	tb.priceInventory(job, result, assets, descriptions)
+}
 
-	// Ограничиваем количество предметов для обработки цен (максимум 50)
-	maxItems := 50
-	if len(assets) > maxItems {
-		tb.sendMessage(chatID, fmt.Sprintf("⚠️ Инвентарь большой (%d предметов). Обрабатываю только первые %d для ускорения.", len(assets), maxItems))
-		assets = assets[:maxItems]
+// Получаем цены предметов, сохраняем результат в кэш и отправляем отчет.
+// Общая часть живого сканирования и импорта из файла
+func (tb *TelegramBot) priceInventory(job *ScanJob, result *ScanResult, assets []Asset, descriptions []Description) {
+	appID := job.AppID
+	totalCount := result.TotalCount
+
+	tb.broadcast(job, fmt.Sprintf("📦 Найдено %d предметов. Обрабатываю цены...", totalCount))
+
+	// Ограничиваем количество предметов для обработки цен
+	if len(assets) > maxPricedItems {
+		tb.broadcast(job, fmt.Sprintf("⚠️ Инвентарь большой (%d предметов). Обрабатываю только первые %d для ускорения.", len(assets), maxPricedItems))
+		assets = assets[:maxPricedItems]
+		result.PricedLimit = maxPricedItems
 	}
 
-	// Обрабатываем предметы
-	items := processInventoryItems(assets, descriptions, appID, false)
+	tb.notifyThrottled(job)
+
+	// Обрабатываем предметы (цены запрашиваются с лимитом market эндпоинта)
+	priceCtx, cancelPrice := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancelPrice()
+
+	items, complete := processInventoryItems(priceCtx, tb.steam, assets, descriptions, appID, false, job.Prices, func(done, total int) {
+		tb.broadcast(job, fmt.Sprintf("💰 Обработано цен: %d из %d", done, total))
+	})
 
 	if len(items) == 0 {
-		tb.sendMessage(chatID, "❌ Нет продаваемых предметов в инвентаре")
+		tb.finishJob(job, noMarketableText)
 		return
 	}
 
-	// Вычисляем статистику
-	var totalValue, minPrice, maxPrice float64
-	var minItem, maxItem string
+	result.Items = items
+	result.PricingPartial = !complete
+	result.Duration = time.Since(result.ScannedAt)
 
-	for i, item := range items {
-		if i == 0 {
-			minPrice = item.PriceValue
-			maxPrice = item.PriceValue
-			minItem = item.Name
-			maxItem = item.Name
-		}
+	tb.recordScan(result)
+	job.result = result
 
-		totalValue += item.PriceValue
+	source := ""
+	if job.Import != nil {
+		source = "из файла"
+	}
 
-		if item.PriceValue < minPrice {
-			minPrice = item.PriceValue
-			minItem = item.Name
-		}
-		if item.PriceValue > maxPrice {
-			maxPrice = item.PriceValue
-			maxItem = item.Name
-		}
+	for _, chatID := range tb.closeJob(job) {
+		tb.sendScanResult(chatID, result, source)
 	}
+}
 
-	duration := time.Since(startTime)
+// Сохраняем результат в кэш. Каждое завершенное сканирование профиля (не файла)
+// попадает в историю и проверяется наблюдениями и правилами
+func (tb *TelegramBot) recordScan(result *ScanResult) {
+	tb.cache.Set(scanKey(result.SteamID, result.AppID, defaultContextID, marketCurrency), result)
 
-	// Формируем ответ
-	gameName := getGameName(appID)
-	response := fmt.Sprintf(`📊 *Статистика инвентаря %s*
+	if isImportID(result.SteamID) {
+		return
+	}
 
-🎮 Игра: %s
-📦 Всего предметов: %d
-💰 Продаваемых: %d
-💵 Общая стоимость: %.2f ₽
+	snapshot := NewSnapshot(result)
+	tb.snapshots.Add(snapshot)
+	tb.checkWatches(snapshot)
+	tb.checkRules(snapshot)
+}
 
-📈 *Ценовая статистика:*
-• Минимальная: %.2f ₽ (%s)
-• Максимальная: %.2f ₽ (%s)
+// Отправляем отчет и топ самых дорогих предметов. Свежий и кэшированный
+// результаты строятся одним и тем же кодом из одних и тех же данных
+func (tb *TelegramBot) sendScanResult(chatID int64, result *ScanResult, source string) {
+	tb.rememberScan(chatID, result)
 
-⏱ Время сканирования: %v`,
-		steamID, gameName, totalCount, len(items), totalValue,
-		minPrice, minItem, maxPrice, maxItem, duration)
+	report := BuildReport(result)
 
-	// Сохраняем в кэш
-	tb.cache.Set(cacheKey, items)
+	tb.sendReport(chatID, report.Format(source), result.SteamID, result.AppID)
 
-	tb.sendMessage(chatID, response)
+	// Показываем топ-5 самых дорогих предметов с кнопками карточек
+	if len(report.TopItems) > 0 {
+		msg := tgbotapi.NewMessage(chatID, report.FormatTopItems())
+		msg.ParseMode = "Markdown"
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(itemButtons(result.SteamID, result.AppID, report.TopItems, 1)...)
+		if _, err := tb.bot.Send(msg); err != nil {
+			log.Printf("Ошибка отправки сообщения: %v", err)
+		}
+	}
 
-	// Показываем топ-5 самых дорогих предметов
-	if len(items) > 0 {
-		tb.sendTopItems(chatID, items)
+	if len(result.Items) > 0 {
+		tb.sendMessage(chatID, report.Breakdown.Format())
 	}
 }
 
-func (tb *TelegramBot) sendTopItems(chatID int64, items []InventoryItem) {
-	// Сортируем по цене (убывание)
-	for i := 0; i < len(items)-1; i++ {
-		for j := i + 1; j < len(items); j++ {
-			if items[i].PriceValue < items[j].PriceValue {
-				items[i], items[j] = items[j], items[i]
-			}
+// Запоминаем последний результат, показанный в чате
+func (tb *TelegramBot) rememberScan(chatID int64, result *ScanResult) {
+	tb.lastScansMutex.Lock()
+	defer tb.lastScansMutex.Unlock()
+
+	tb.lastScans[chatID] = scanKey(result.SteamID, result.AppID, defaultContextID, marketCurrency)
+}
+
+// Последний результат сканирования в чате. Если его нет или он вытеснен из кэша,
+// сообщаем об этом пользователю
+func (tb *TelegramBot) lastScan(chatID int64) (*ScanResult, bool) {
+	tb.lastScansMutex.Lock()
+	key, exists := tb.lastScans[chatID]
+	tb.lastScansMutex.Unlock()
+
+	if !exists {
+		tb.sendMessage(chatID, "Сначала отсканируйте инвентарь: /scan <steam\\_id>")
+		return nil, false
+	}
+
+	entry, exists := tb.cache.GetEntry(key)
+	if !exists {
+		tb.sendMessage(chatID, "⌛ Результат сканирования устарел. Отсканируйте инвентарь заново.")
+		return nil, false
+	}
+	return entry.Value, true
+}
+
+func (tb *TelegramBot) sendStatusMessage(chatID int64) {
+	text := "🩺 *Состояние сервисов Steam*\n\n"
+
+	for _, status := range tb.steam.Status() {
+		text += fmt.Sprintf("*%s*: %s\n", status.Endpoint, status.State)
+		if status.Stats.Requests > 0 {
+			text += fmt.Sprintf("• Задержка: %v (p95 %v)\n• Ошибки: %.0f%% из %d запросов\n",
+				status.Stats.AvgLatency.Round(time.Millisecond), status.Stats.P95Latency.Round(time.Millisecond),
+				status.Stats.ErrorRate*100, status.Stats.Requests)
+		}
+		if status.RateShare < 1 {
+			text += fmt.Sprintf("• Скорость снижена до %.0f%% из-за 429\n", status.RateShare*100)
 		}
+		text += "\n"
 	}
 
-	text := "🏆 *Топ-5 самых дорогих предметов:*\n\n"
+	inventoryStats := tb.cache.Stats()
+	priceStats := tb.steam.prices.Stats()
+
+	queued, running := tb.queue.Depth()
+	text += fmt.Sprintf("📥 Очередь: %d в ожидании, %d выполняется\n", queued, running)
+	text += fmt.Sprintf("⚡ Кэш инвентарей: %.0f%% попаданий, %d записей (%.1f МБ), вытеснено %d\n",
+		inventoryStats.HitRatio()*100, inventoryStats.Entries, float64(inventoryStats.Bytes)/(1<<20), inventoryStats.Evictions)
+	text += fmt.Sprintf("💰 Кэш цен: %.0f%% попаданий, %d записей", priceStats.HitRatio()*100, priceStats.Entries)
 
-	topCount := 5
-	if len(items) < topCount {
-		topCount = len(items)
+	tb.sendMessage(chatID, text)
+}
+
+// Предупреждаем пользователя, что Steam ограничивает запросы и сканирование задержится.
+// Предупреждение отправляется один раз за задание
+func (tb *TelegramBot) notifyThrottled(job *ScanJob) {
+	if job.throttleNotified || !tb.steam.Throttled() {
+		return
 	}
 
-	for i := 0; i < topCount; i++ {
-		item := items[i]
-		text += fmt.Sprintf("%d. *%s*\n   💰 %.2f ₽\n\n", i+1, item.Name, item.PriceValue)
+	job.throttleNotified = true
+	tb.broadcast(job, "🐢 Steam сейчас ограничивает частоту запросов. Сканирование займет больше времени, чем обычно.")
+}
+
+// Отправляем отчет с кнопками браузера предметов, обновления, витрины, графиков и экспорта
+func (tb *TelegramBot) sendReport(chatID int64, text, steamID, appID string) {
+	browser := browserState{SteamID: steamID, AppID: appID, Sort: sortByPrice, Filter: filterAll}
+
+	firstRow := tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("📋 Все предметы", browser.callbackData("browsenew")),
+	)
+	// Загруженный из файла инвентарь пересканировать нечем
+	if !isImportID(steamID) {
+		firstRow = append(firstRow, tgbotapi.NewInlineKeyboardButtonData("🔄 Обновить", fmt.Sprintf("refresh_%s_%s", steamID, appID)))
 	}
 
-	tb.sendMessage(chatID, text)
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		firstRow,
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🖼 Витрина", fmt.Sprintf("showcase_%s_%s", steamID, appID)),
+			tgbotapi.NewInlineKeyboardButtonData("📊 Графики", fmt.Sprintf("charts_%s_%s", steamID, appID)),
+			tgbotapi.NewInlineKeyboardButtonData("📤 Экспорт", fmt.Sprintf("export_%s_%s", steamID, appID)),
+		),
+	)
+
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ParseMode = "Markdown"
+	msg.ReplyMarkup = keyboard
+
+	if _, err := tb.bot.Send(msg); err != nil {
+		log.Printf("Ошибка отправки сообщения: %v", err)
+	}
+}
+
+func (tb *TelegramBot) editMessage(chatID int64, messageID int, text string) {
+	edit := tgbotapi.NewEditMessageText(chatID, messageID, text)
+
+	if _, err := tb.bot.Send(edit); err != nil {
+		log.Printf("Ошибка редактирования сообщения: %v", err)
+	}
 }
 
 func (tb *TelegramBot) sendMessage(chatID int64, text string) {
@@ -428,6 +882,57 @@ func (tb *TelegramBot) sendMessage(chatID int64, text string) {
 	}
 }
 
+// Кнопки удаления элементов списка "❌ 1", "❌ 2"... по пять в ряд.
+// callbacks - данные кнопок в порядке элементов; для пустого списка nil
+func deleteKeyboard(callbacks []string) *tgbotapi.InlineKeyboardMarkup {
+	if len(callbacks) == 0 {
+		return nil
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	var row []tgbotapi.InlineKeyboardButton
+	for i, data := range callbacks {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("❌ %d", i+1), data))
+		if len(row) == 5 {
+			rows = append(rows, row)
+			row = nil
+		}
+	}
+	if len(row) > 0 {
+		rows = append(rows, row)
+	}
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	return &keyboard
+}
+
+// Отправляем список с кнопками удаления (keyboard может быть nil)
+func (tb *TelegramBot) sendList(chatID int64, text string, keyboard *tgbotapi.InlineKeyboardMarkup) {
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ParseMode = "Markdown"
+	if keyboard != nil {
+		msg.ReplyMarkup = *keyboard
+	}
+
+	if _, err := tb.bot.Send(msg); err != nil {
+		log.Printf("Ошибка отправки сообщения: %v", err)
+	}
+}
+
+// Обновляем список после удаления элемента; у пустого списка кнопки убираем
+func (tb *TelegramBot) editList(chatID int64, messageID int, text string, keyboard *tgbotapi.InlineKeyboardMarkup) {
+	if keyboard == nil {
+		keyboard = &tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{}}
+	}
+
+	edit := tgbotapi.NewEditMessageTextAndMarkup(chatID, messageID, text, *keyboard)
+	edit.ParseMode = "Markdown"
+
+	if _, err := tb.bot.Send(edit); err != nil {
+		log.Printf("Ошибка редактирования сообщения: %v", err)
+	}
+}
+
 func (tb *TelegramBot) isSteamInput(text string) bool {
 	// Проверяем, похоже ли на Steam ID или ссылку
 	return strings.Contains(text, "steamcommunity.com") ||
@@ -435,6 +940,18 @@ func (tb *TelegramBot) isSteamInput(text string) bool {
 		len(text) > 10 && strings.Contains(text, "/")
 }
 
+// Возраст данных для отчета
+func formatAge(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return "только что"
+	case d < time.Hour:
+		return fmt.Sprintf("%d мин назад", int(d.Minutes()))
+	default:
+		return fmt.Sprintf("%d ч %d мин назад", int(d.Hours()), int(d.Minutes())%60)
+	}
+}
+
 func getGameName(appID string) string {
 	switch appID {
 	case "730":
@@ -450,18 +967,36 @@ func getGameName(appID string) string {
 	}
 }
 
-func processInventoryItems(assets []Asset, descriptions []Description, appID string, debug bool) []InventoryItem {
+// Сколько предметов обрабатываем между сообщениями о прогрессе
+const progressEvery = 10
+
+// Оцениваем продаваемые предметы. complete = false, если получение цен
+// прервалось (таймаут или недоступность торговой площадки).
+// prices - уже полученные цены по market_hash_name: при оценке нескольких инвентарей
+// одной игры каждая цена запрашивается один раз (nil - свой словарь)
+func processInventoryItems(ctx context.Context, steam *SteamClient, assets []Asset, descriptions []Description, appID string, debug bool, prices map[string]MarketPrice, progress func(done, total int)) (items []InventoryItem, complete bool) {
 	descMap := make(map[string]Description)
 	for _, desc := range descriptions {
 		key := desc.ClassID + "_" + desc.InstanceID
 		descMap[key] = desc
 	}
 
-	priceCache := make(map[string]string)
-	var items []InventoryItem
-	processedCount := 0
+	if prices == nil {
+		prices = make(map[string]MarketPrice)
+	}
+	// Неудачные запросы не попадают в общий словарь: другой инвентарь спросит цену снова.
+	// В этом инвентаре повторно не спрашиваем
+	failed := make(map[string]bool)
+
+	for i, asset := range assets {
+		if ctx.Err() != nil {
+			return items, false
+		}
+
+		if progress != nil && i > 0 && i%progressEvery == 0 {
+			progress(i, len(assets))
+		}
 
-	for _, asset := range assets {
 		key := asset.ClassID + "_" + asset.InstanceID
 		desc, found := descMap[key]
 
@@ -469,36 +1004,69 @@ func processInventoryItems(assets []Asset, descriptions []Description, appID str
 			continue
 		}
 
-		price, cached := priceCache[desc.MarketHashName]
+		if failed[desc.MarketHashName] {
+			continue
+		}
+
+		overview, cached := prices[desc.MarketHashName]
 		if !cached {
-			price = getMarketPrice(appID, desc.MarketHashName, debug)
-			priceCache[desc.MarketHashName] = price
-			time.Sleep(3 * time.Second) // Уменьшили задержку
+			var err error
+			overview, err = steam.getMarketOverview(ctx, appID, desc.MarketHashName, debug)
+			switch {
+			case errors.Is(err, ErrCircuitOpen):
+				// Торговая площадка недоступна: остальные цены тоже не получим
+				return items, false
+			case err != nil:
+				failed[desc.MarketHashName] = true
+				continue
+			}
+			prices[desc.MarketHashName] = overview
 		}
 
-		if price == "" {
+		if overview.Lowest == "" {
 			continue
 		}
 
+		price := fmt.Sprintf("%s (lowest)", overview.Lowest)
 		priceValue := parsePrice(price)
+		amount, _ := strconv.Atoi(asset.Amount)
 		item := InventoryItem{
-			Name:       desc.Name,
-			MarketName: desc.MarketName,
-			Type:       desc.Type,
-			Price:      price,
-			PriceValue: priceValue,
-			AssetID:    asset.AssetID,
+			Name:           desc.Name,
+			MarketName:     desc.MarketName,
+			MarketHashName: desc.MarketHashName,
+			Type:           desc.Type,
+			Price:          price,
+			PriceValue:     priceValue,
+			Volume:         parseVolume(overview.Volume),
+			AssetID:        asset.AssetID,
+			Amount:         amount,
+			Tradable:       desc.Tradable == 1,
+			Tags:           desc.Tags,
+			IconURL:        desc.IconURL,
+			IconURLLarge:   desc.IconURLLarge,
+			InspectLink:    inspectLink(desc),
+			NameColor:      desc.NameColor,
 		}
 		items = append(items, item)
-		processedCount++
+	}
 
-		// Обновляем прогресс каждые 3 предмета
-		if processedCount%3 == 0 {
-			time.Sleep(100 * time.Millisecond)
-		}
+	return items, true
+}
+
+// Сколько предметов можно продать на торговой площадке
+func countMarketable(assets []Asset, descriptions []Description) int {
+	marketable := make(map[string]bool)
+	for _, desc := range descriptions {
+		marketable[desc.ClassID+"_"+desc.InstanceID] = desc.Marketable == 1
 	}
 
-	return items
+	count := 0
+	for _, asset := range assets {
+		if marketable[asset.ClassID+"_"+asset.InstanceID] {
+			count++
+		}
+	}
+	return count
 }
 
 func main() {
@@ -510,6 +1078,8 @@ func main() {
 		log.Fatal("Ошибка создания бота:", err)
 	}
 
+	defer bot.Close()
+
 	log.Println("Бот запущен...")
 	bot.Start()
 }
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// Адаптивное снижение частоты запросов (AIMD): при ответе 429 скорость эндпоинта
+// уменьшается вдвое для всех воркеров, после успешных запросов медленно растет обратно
+type AdaptiveThrottle struct {
+	mutex     sync.Mutex
+	limiter   *RateLimiter
+	base      map[Endpoint]float64
+	throttled map[Endpoint]time.Time
+
+	decrease float64       // множитель скорости при 429
+	increase float64       // доля базовой скорости, добавляемая за успешный запрос
+	minShare float64       // нижняя граница скорости (доля базовой)
+	cooldown time.Duration // не снижаем скорость чаще, чем раз в cooldown
+}
+
+// Создаем контроллер поверх лимитера, запоминая текущие скорости как базовые
+func NewAdaptiveThrottle(limiter *RateLimiter) *AdaptiveThrottle {
+	at := &AdaptiveThrottle{
+		limiter:   limiter,
+		base:      make(map[Endpoint]float64),
+		throttled: make(map[Endpoint]time.Time),
+		decrease:  0.5,
+		increase:  0.02,
+		minShare:  0.05,
+		cooldown:  5 * time.Second,
+	}
+
+	for endpoint, bucket := range limiter.buckets {
+		at.base[endpoint] = bucket.Rate()
+	}
+
+	return at
+}
+
+// Steam ответил 429: снижаем скорость эндпоинта
+func (at *AdaptiveThrottle) OnThrottled(endpoint Endpoint) {
+	bucket := at.limiter.Bucket(endpoint)
+	if bucket == nil {
+		return
+	}
+
+	at.mutex.Lock()
+	defer at.mutex.Unlock()
+
+	// Несколько воркеров получают 429 почти одновременно - считаем это одним событием
+	if last, exists := at.throttled[endpoint]; exists && time.Since(last) < at.cooldown {
+		bucket.Drain()
+		return
+	}
+	at.throttled[endpoint] = time.Now()
+
+	rate := bucket.Rate() * at.decrease
+	if min := at.base[endpoint] * at.minShare; rate < min {
+		rate = min
+	}
+
+	bucket.SetRate(rate)
+	bucket.Drain()
+}
+
+// Успешный запрос: понемногу возвращаем скорость к базовой
+func (at *AdaptiveThrottle) OnSuccess(endpoint Endpoint) {
+	bucket := at.limiter.Bucket(endpoint)
+	if bucket == nil {
+		return
+	}
+
+	at.mutex.Lock()
+	defer at.mutex.Unlock()
+
+	if _, exists := at.throttled[endpoint]; !exists {
+		return
+	}
+
+	base := at.base[endpoint]
+	rate := bucket.Rate() + base*at.increase
+	if rate >= base {
+		rate = base
+		delete(at.throttled, endpoint)
+	}
+
+	bucket.SetRate(rate)
+}
+
+// Снижена ли сейчас скорость хотя бы одного эндпоинта
+func (at *AdaptiveThrottle) Throttled() bool {
+	at.mutex.Lock()
+	defer at.mutex.Unlock()
+
+	return len(at.throttled) > 0
+}
+
+// Текущая доля базовой скорости эндпоинта (1 - без ограничений)
+func (at *AdaptiveThrottle) Share(endpoint Endpoint) float64 {
+	bucket := at.limiter.Bucket(endpoint)
+	if bucket == nil {
+		return 1
+	}
+
+	at.mutex.Lock()
+	base := at.base[endpoint]
+	at.mutex.Unlock()
+
+	if base <= 0 {
+		return 1
+	}
+	return bucket.Rate() / base
+}
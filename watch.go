@@ -0,0 +1,312 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Вид задач планировщика для наблюдения за профилями
+const watchKind = "watch"
+
+// Настройки наблюдения по умолчанию и ограничения
+const (
+	defaultWatchInterval  = 6 * time.Hour
+	minWatchInterval      = time.Hour
+	maxWatchInterval      = 7 * 24 * time.Hour
+	defaultWatchThreshold = 5.0 // процент изменения стоимости
+	maxWatchesPerUser     = 10
+
+	// Через сколько повторить проверку, если Steam или очередь заняты
+	watchRetryDelay = 10 * time.Minute
+	// Плановые сканирования ждут, пока в очереди столько ручных заданий
+	watchQueueLimit = 10
+)
+
+// Данные задачи наблюдения
+type WatchPayload struct {
+	SteamID   string    `json:"steam_id"`
+	AppID     string    `json:"app_id"`
+	Threshold float64   `json:"threshold"`
+	Baseline  time.Time `json:"baseline"` // снимок, о котором чат уже знает
+}
+
+func watchID(chatID int64, steamID, appID string) string {
+	return fmt.Sprintf("watch_%d_%s_%s", chatID, steamID, appID)
+}
+
+// Наблюдения, созданные пользователем (во всех чатах)
+func (tb *TelegramBot) userWatches(userID int64) []ScheduledTask {
+	return tb.scheduler.List(watchKind, func(task ScheduledTask) bool {
+		return task.UserID == userID
+	})
+}
+
+func (tb *TelegramBot) handleWatchCommand(chatID, userID int64, text string) {
+	parts := strings.Fields(text)
+	if len(parts) < 2 {
+		tb.sendWatchList(chatID)
+		return
+	}
+
+	switch parts[1] {
+	case "interval":
+		tb.setWatchInterval(chatID, userID, parts[2:])
+		return
+	case "threshold":
+		tb.setWatchThreshold(chatID, userID, parts[2:])
+		return
+	}
+
+	steamID, ok := tb.resolveInput(chatID, parts[1])
+	if !ok {
+		return
+	}
+
+	appID := "730" // CS:GO по умолчанию
+	if len(parts) > 2 {
+		appID = parts[2]
+	}
+
+	id := watchID(chatID, steamID, appID)
+	if _, exists := tb.scheduler.Get(id); exists {
+		tb.sendMessage(chatID, "👁 Этот профиль уже под наблюдением в этом чате. Список: /watch")
+		return
+	}
+
+	// Настройки пользователя берем из его существующих наблюдений
+	watches := tb.userWatches(userID)
+	if len(watches) >= maxWatchesPerUser {
+		tb.sendMessage(chatID, fmt.Sprintf("🚦 Можно следить не больше чем за %d профилями. Уберите лишние: /unwatch", maxWatchesPerUser))
+		return
+	}
+
+	interval, threshold := defaultWatchInterval, defaultWatchThreshold
+	if len(watches) > 0 {
+		interval = watches[0].Interval
+		if payload, ok := taskPayload[WatchPayload](watches[0]); ok {
+			threshold = payload.Threshold
+		}
+	}
+
+	payload := WatchPayload{SteamID: steamID, AppID: appID, Threshold: threshold}
+	task := newTask(id, watchKind, chatID, userID, payload)
+	task.Interval = interval
+
+	// Есть снимок - сравниваем с ним через интервал, нет - сканируем сразу
+	if latest, exists := tb.snapshots.Latest(steamID, appID); exists {
+		payload.Baseline = latest.TakenAt
+		task.setPayload(payload)
+		task.NextRun = time.Now().Add(interval)
+	} else {
+		task.NextRun = time.Now()
+	}
+
+	tb.scheduler.Add(task)
+
+	tb.sendMessage(chatID, fmt.Sprintf("👁 Слежу за профилем %s (%s).\nПроверка каждые %s, сообщу о новых и ушедших предметах и об изменении стоимости больше чем на %g%%.\nВ больших инвентарях сравниваются первые %d предметов.",
+		steamID, getGameName(appID), formatInterval(interval), threshold, maxPricedItems))
+}
+
+func (tb *TelegramBot) handleUnwatchCommand(chatID int64, text string) {
+	parts := strings.Fields(text)
+	if len(parts) < 2 {
+		tb.sendMessage(chatID, "Использование: /unwatch <steam\\_id> [app\\_id]")
+		return
+	}
+
+	steamID, ok := tb.resolveInput(chatID, parts[1])
+	if !ok {
+		return
+	}
+
+	appID := "730" // CS:GO по умолчанию
+	if len(parts) > 2 {
+		appID = parts[2]
+	}
+
+	if !tb.scheduler.Remove(watchID(chatID, steamID, appID)) {
+		tb.sendMessage(chatID, "Этот профиль не под наблюдением в этом чате. Список: /watch")
+		return
+	}
+
+	tb.sendMessage(chatID, fmt.Sprintf("✅ Больше не слежу за профилем %s (%s)", steamID, getGameName(appID)))
+}
+
+// Список наблюдений чата
+func (tb *TelegramBot) sendWatchList(chatID int64) {
+	watches := tb.scheduler.List(watchKind, func(task ScheduledTask) bool {
+		return task.ChatID == chatID
+	})
+
+	if len(watches) == 0 {
+		tb.sendMessage(chatID, "👁 В этом чате нет наблюдаемых профилей.\nДобавить: /watch <steam\\_id> [app\\_id]")
+		return
+	}
+
+	var b strings.Builder
+	b.WriteString("👁 *Наблюдаемые профили*\n\n")
+	for _, task := range watches {
+		payload, ok := taskPayload[WatchPayload](task)
+		if !ok {
+			continue
+		}
+		fmt.Fprintf(&b, "• %s (%s): каждые %s, порог %g%%, следующая проверка %s\n",
+			payload.SteamID, getGameName(payload.AppID), formatInterval(task.Interval), payload.Threshold, task.NextRun.Format("02.01 15:04"))
+	}
+	b.WriteString("\nНастройки: /watch interval <часы>, /watch threshold <процент>\nУбрать: /unwatch <steam\\_id> [app\\_id]")
+
+	tb.sendMessage(chatID, b.String())
+}
+
+// Интервал проверок для всех наблюдений пользователя
+func (tb *TelegramBot) setWatchInterval(chatID, userID int64, args []string) {
+	hours, err := strconv.Atoi(strings.Join(args, ""))
+	interval := time.Duration(hours) * time.Hour
+	if err != nil || interval < minWatchInterval || interval > maxWatchInterval {
+		tb.sendMessage(chatID, fmt.Sprintf("Использование: /watch interval <часы>, от %d до %d", int(minWatchInterval.Hours()), int(maxWatchInterval.Hours())))
+		return
+	}
+
+	watches := tb.userWatches(userID)
+	for _, watch := range watches {
+		tb.scheduler.Update(watch.ID, func(task *ScheduledTask) bool {
+			// Следующая проверка не позже, чем через новый интервал
+			if next := time.Now().Add(interval); task.NextRun.After(next) {
+				task.NextRun = next
+			}
+			task.Interval = interval
+			return true
+		})
+	}
+
+	tb.sendMessage(chatID, fmt.Sprintf("✅ Интервал проверок: %s (наблюдений: %d)", formatInterval(interval), len(watches)))
+}
+
+// Порог изменения стоимости для всех наблюдений пользователя
+func (tb *TelegramBot) setWatchThreshold(chatID, userID int64, args []string) {
+	threshold, err := strconv.ParseFloat(strings.TrimSuffix(strings.Join(args, ""), "%"), 64)
+	if err != nil || threshold <= 0 || threshold > 100 {
+		tb.sendMessage(chatID, "Использование: /watch threshold <процент>, например /watch threshold 10")
+		return
+	}
+
+	watches := tb.userWatches(userID)
+	for _, watch := range watches {
+		tb.scheduler.Update(watch.ID, func(task *ScheduledTask) bool {
+			payload, ok := taskPayload[WatchPayload](*task)
+			if !ok {
+				return false
+			}
+			payload.Threshold = threshold
+			task.setPayload(payload)
+			return true
+		})
+	}
+
+	tb.sendMessage(chatID, fmt.Sprintf("✅ Порог изменения стоимости: %g%% (наблюдений: %d)", threshold, len(watches)))
+}
+
+// Плановая проверка: ставим пересканирование профиля в общую очередь.
+// Сами изменения проверяет checkWatches, когда снимок будет готов
+func (tb *TelegramBot) runWatch(task ScheduledTask) time.Time {
+	payload, ok := taskPayload[WatchPayload](task)
+	if !ok {
+		return time.Time{}
+	}
+
+	now := time.Now()
+
+	// Профиль недавно сканировали: его снимок уже проверен
+	if tb.cache.Fresh(scanKey(payload.SteamID, payload.AppID, defaultContextID, marketCurrency)) {
+		return now.Add(task.Interval)
+	}
+
+	// Плановые сканирования не тратят бюджет запросов, пока Steam ограничивает
+	// частоту или пользователи ждут в очереди
+	queued, _ := tb.queue.Depth()
+	if !tb.steam.Available(EndpointInventory) || tb.steam.Throttled() || queued >= watchQueueLimit {
+		return now.Add(watchRetryDelay)
+	}
+
+	if !tb.enqueueScheduled(task.UserID, payload.SteamID, payload.AppID) {
+		return now.Add(watchRetryDelay)
+	}
+
+	return now.Add(task.Interval)
+}
+
+// Сравниваем новый снимок с тем, о котором знают наблюдающие чаты,
+// и сообщаем о новых и ушедших предметах или заметном изменении стоимости
+func (tb *TelegramBot) checkWatches(snapshot *Snapshot) {
+	// По прерванному сканированию изменения состава не определить. Снимки
+	// с лимитом оцениваемых предметов сравниваем: diff.Format отметит, что они неполные
+	if snapshot.Interrupted {
+		return
+	}
+
+	watches := tb.scheduler.List(watchKind, func(task ScheduledTask) bool {
+		payload, ok := taskPayload[WatchPayload](task)
+		return ok && payload.SteamID == snapshot.SteamID && payload.AppID == snapshot.AppID
+	})
+	if len(watches) == 0 {
+		return
+	}
+
+	snapshots := tb.snapshots.List(snapshot.SteamID, snapshot.AppID)
+
+	for _, watch := range watches {
+		payload, _ := taskPayload[WatchPayload](watch)
+
+		baseline := findSnapshot(snapshots, payload.Baseline)
+		if baseline != nil && baseline != snapshot {
+			diff := DiffSnapshots(baseline, snapshot)
+			if diff.HoldingsUnchanged() && !exceedsThreshold(baseline.TotalValue, snapshot.TotalValue, payload.Threshold) {
+				continue
+			}
+			tb.sendMessage(watch.ChatID, "👁 *Изменения в наблюдаемом профиле*\n\n"+diff.Format())
+		}
+
+		// Следующее сравнение - с этим снимком
+		tb.scheduler.Update(watch.ID, func(task *ScheduledTask) bool {
+			current, ok := taskPayload[WatchPayload](*task)
+			if !ok {
+				return false
+			}
+			current.Baseline = snapshot.TakenAt
+			task.setPayload(current)
+			return true
+		})
+	}
+}
+
+// Снимок, сделанный в момент takenAt
+func findSnapshot(snapshots []*Snapshot, takenAt time.Time) *Snapshot {
+	if takenAt.IsZero() {
+		return nil
+	}
+	for _, snapshot := range snapshots {
+		if snapshot.TakenAt.Equal(takenAt) {
+			return snapshot
+		}
+	}
+	return nil
+}
+
+// Изменилась ли стоимость больше чем на threshold процентов
+func exceedsThreshold(from, to, threshold float64) bool {
+	if from == 0 {
+		return to != 0
+	}
+	return math.Abs(to-from)/from*100 >= threshold
+}
+
+// 6 ч, 2 д
+func formatInterval(d time.Duration) string {
+	if d >= 24*time.Hour && d%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%d д", int(d.Hours()/24))
+	}
+	return fmt.Sprintf("%d ч", int(d.Hours()))
+}